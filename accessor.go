@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClusterAccessor provides access to a Kubernetes cluster without requiring the
+// connection to be established when the accessor is created. It is intended to be
+// passed around in dependency-injection graphs and plugin systems where the moment
+// an object is constructed should be decoupled from the moment it connects.
+type ClusterAccessor interface {
+	// Clientset returns a clientset connected to the cluster, connecting on first use.
+	Clientset() (kubernetes.Interface, error)
+
+	// Config returns the configuration the accessor connects with.
+	Config() K8sConfig
+}
+
+// lazyClusterAccessor is a ClusterAccessor that builds its clientset on the first
+// call to Clientset and memoizes it for all subsequent calls.
+type lazyClusterAccessor struct {
+	config K8sConfig
+
+	mu        sync.Mutex
+	clientset kubernetes.Interface
+}
+
+// NewLazyClusterAccessor returns a ClusterAccessor for an external cluster that does not
+// connect until Clientset is first called.
+//
+// The first call to Clientset builds the clientset with CreateExternalClusterKubeRestClient.
+// A successfully built clientset is memoized and returned to every later caller. A failed
+// attempt is not memoized, so the next call to Clientset retries the connection; this lets
+// callers recover from a cluster that was briefly unreachable when it was first needed.
+// Concurrent callers are serialized so at most one connection attempt is in flight.
+//
+// Parameters:
+//
+//	k8sconfig: A K8sConfig struct containing the connection details and credentials
+//	           for the target Kubernetes cluster.
+//
+// Returns:
+//
+//	A ClusterAccessor that connects lazily to the configured cluster.
+func NewLazyClusterAccessor(k8sconfig K8sConfig) ClusterAccessor {
+	return &lazyClusterAccessor{config: k8sconfig}
+}
+
+// Clientset returns the memoized clientset, building it if no previous attempt succeeded.
+func (a *lazyClusterAccessor) Clientset() (kubernetes.Interface, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.clientset != nil {
+		return a.clientset, nil
+	}
+
+	clientset, err := CreateExternalClusterKubeRestClient(a.config)
+	if err != nil {
+		return nil, err
+	}
+
+	a.clientset = clientset
+	return a.clientset, nil
+}
+
+// Config returns the configuration the accessor connects with.
+func (a *lazyClusterAccessor) Config() K8sConfig {
+	return a.config
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+	batchv1client "k8s.io/client-go/kubernetes/typed/batch/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// ClientSetBundle groups the typed, dynamic, and discovery clients for one cluster, all
+// built from a single rest.Config and sharing a single HTTP client. Every client in the
+// bundle reuses the same transport and connection pool, so a process running several
+// controllers against one cluster holds one pool rather than one per client.
+type ClientSetBundle struct {
+	config     *rest.Config
+	httpClient *http.Client
+
+	kubernetes *kubernetes.Clientset
+	dynamic    *dynamic.DynamicClient
+	discovery  *discovery.DiscoveryClient
+}
+
+// NewClientSetBundle builds the shared HTTP client for restConfig once and creates the
+// typed, dynamic, and discovery clients on top of it. No network requests are made.
+//
+// Parameters:
+//
+//	restConfig: The REST configuration used to reach the API server, for example one
+//	            returned by BuildRestConfig or rest.InClusterConfig.
+//
+// Returns:
+//
+//	A pointer to a ClientSetBundle whose clients share one connection pool.
+//	An error if the HTTP client or any of the clients cannot be created.
+func NewClientSetBundle(restConfig *rest.Config) (*ClientSetBundle, error) {
+	httpClient, err := rest.HTTPClientFor(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shared HTTP client: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfigAndClient(restConfig, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfigAndClient(restConfig, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfigAndClient(restConfig, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	return &ClientSetBundle{
+		config:     restConfig,
+		httpClient: httpClient,
+		kubernetes: clientset,
+		dynamic:    dynamicClient,
+		discovery:  discoveryClient,
+	}, nil
+}
+
+// Config returns the rest.Config the bundle was built from.
+func (b *ClientSetBundle) Config() *rest.Config {
+	return b.config
+}
+
+// Kubernetes returns the typed clientset covering every built-in API group.
+func (b *ClientSetBundle) Kubernetes() kubernetes.Interface {
+	return b.kubernetes
+}
+
+// CoreV1 returns the typed client for the core/v1 API group.
+func (b *ClientSetBundle) CoreV1() corev1client.CoreV1Interface {
+	return b.kubernetes.CoreV1()
+}
+
+// AppsV1 returns the typed client for the apps/v1 API group.
+func (b *ClientSetBundle) AppsV1() appsv1client.AppsV1Interface {
+	return b.kubernetes.AppsV1()
+}
+
+// BatchV1 returns the typed client for the batch/v1 API group.
+func (b *ClientSetBundle) BatchV1() batchv1client.BatchV1Interface {
+	return b.kubernetes.BatchV1()
+}
+
+// Dynamic returns the dynamic client for working with arbitrary resources.
+func (b *ClientSetBundle) Dynamic() dynamic.Interface {
+	return b.dynamic
+}
+
+// Discovery returns the discovery client.
+func (b *ClientSetBundle) Discovery() discovery.DiscoveryInterface {
+	return b.discovery
+}
+
+// Close releases the idle connections held by the bundle's shared transport. Requests
+// already in flight are not interrupted. client-go caches transports by TLS settings, so
+// other clients built from an identical configuration may share the same transport; they
+// are unaffected beyond having to open new connections. The bundle's clients remain usable
+// after Close, but will dial new connections.
+func (b *ClientSetBundle) Close() {
+	utilnet.CloseIdleConnectionsFor(b.httpClient.Transport)
+}
+
+// CloseIdleConnections releases the idle keep-alive connections held by clientset's
+// transport, for services that build and discard many short-lived clients and would
+// otherwise accumulate open sockets. Requests in flight are not interrupted, and the
+// clientset remains usable, dialing new connections as needed.
+//
+// It is safe with shared transports: client-go caches transports by TLS settings, so
+// clients built from an identical configuration may share this one, and they simply
+// open new connections on their next request. Every API group of a clientset shares one
+// HTTP client, so all of them are covered.
+//
+// Parameters:
+//
+//	clientset: The clientset whose idle connections to close.
+func CloseIdleConnections(clientset *kubernetes.Clientset) {
+	restClient, ok := clientset.CoreV1().RESTClient().(*rest.RESTClient)
+	if !ok || restClient.Client == nil {
+		return
+	}
+	utilnet.CloseIdleConnectionsFor(restClient.Client.Transport)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClusterInfo is a point-in-time inventory record for a single cluster. It gathers the
+// results of several discovery and list calls into one flat value that can be cached,
+// serialized, and compared with a previous poll to detect changes.
+type ClusterInfo struct {
+	// GitVersion is the full version string reported by the API server, e.g. "v1.30.2".
+	GitVersion string `json:"gitVersion"`
+
+	// Major and Minor are the major and minor version components reported by the API server.
+	Major string `json:"major"`
+	Minor string `json:"minor"`
+
+	// Platform is the OS/architecture the API server binary was built for, e.g. "linux/amd64".
+	Platform string `json:"platform"`
+
+	// APIGroupCount is the number of API groups served by the cluster, including the core group.
+	APIGroupCount int `json:"apiGroupCount"`
+
+	// NodeCount is the number of nodes registered with the cluster.
+	NodeCount int `json:"nodeCount"`
+
+	// CollectedAt records when the information was gathered. It is ignored by Changed.
+	CollectedAt time.Time `json:"collectedAt"`
+}
+
+// Changed reports whether any inventory field differs between info and previous,
+// ignoring CollectedAt. A nil previous is always considered a change.
+func (info *ClusterInfo) Changed(previous *ClusterInfo) bool {
+	if previous == nil {
+		return true
+	}
+
+	current, prior := *info, *previous
+	current.CollectedAt, prior.CollectedAt = time.Time{}, time.Time{}
+	return current != prior
+}
+
+// GetClusterInfo collects a ClusterInfo inventory record for the cluster the clientset is
+// connected to. It fetches the server version, counts the served API groups, and counts
+// the registered nodes in pages of 500 with ListAll, restarting the count if a continue
+// token expires part way through, so large clusters are handled efficiently.
+//
+// Parameters:
+//
+//	ctx: The context used for the version and node list requests.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//
+// Returns:
+//
+//	A pointer to a ClusterInfo populated with the cluster's current inventory.
+//	An error if any of the underlying requests fail, otherwise nil.
+func GetClusterInfo(ctx context.Context, clientset kubernetes.Interface) (*ClusterInfo, error) {
+	serverVersion, err := getServerVersion(ctx, clientset.Discovery())
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := clientset.Discovery().ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server API groups: %w", err)
+	}
+
+	listNodes := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return clientset.CoreV1().Nodes().List(ctx, opts)
+	}
+	opts := ListAllOptions{RestartOnExpired: true, PageSize: defaultPageSize}
+	nodes, err := ListAll[corev1.Node](ctx, listNodes, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	return &ClusterInfo{
+		GitVersion:    serverVersion.GitVersion,
+		Major:         serverVersion.Major,
+		Minor:         serverVersion.Minor,
+		Platform:      serverVersion.Platform,
+		APIGroupCount: len(groups.Groups),
+		NodeCount:     len(nodes),
+		CollectedAt:   time.Now().UTC(),
+	}, nil
+}
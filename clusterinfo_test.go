@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGetClusterInfoFakeClientset(t *testing.T) {
+	clientset := newFakeClientsetWithVersion("v1.30.2")
+	for _, name := range []string{"node-a", "node-b"} {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if _, err := clientset.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("failed to create node %s: %v", name, err)
+		}
+	}
+
+	info, err := GetClusterInfo(context.Background(), clientset)
+	if err != nil {
+		t.Fatalf("GetClusterInfo() error = %v", err)
+	}
+	if info.GitVersion != "v1.30.2" {
+		t.Errorf("GitVersion = %q, want %q", info.GitVersion, "v1.30.2")
+	}
+	if info.NodeCount != 2 {
+		t.Errorf("NodeCount = %d, want 2", info.NodeCount)
+	}
+}
+
+func TestGetClusterInfoRestartsOnExpired(t *testing.T) {
+	objects := newNodeObjects(defaultPageSize + 1)
+
+	var limits []int64
+	expired := false
+	pages := pagedListReactor(objects, newNodeList, &limits)
+	clientset := newFakeClientsetWithVersion("v1.30.2")
+	clientset.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		// The first continue token expires, as when the list outlives etcd compaction
+		if action.(k8stesting.ListActionImpl).GetListOptions().Continue != "" && !expired {
+			expired = true
+			return true, nil, apierrors.NewResourceExpired("continue token expired")
+		}
+		return pages(action)
+	})
+
+	info, err := GetClusterInfo(context.Background(), clientset)
+	if err != nil {
+		t.Fatalf("GetClusterInfo() error = %v", err)
+	}
+	if info.NodeCount != len(objects) {
+		t.Errorf("NodeCount = %d, want %d", info.NodeCount, len(objects))
+	}
+	// Both pages were read after the restart, each requested with the default page size
+	if len(limits) != 3 {
+		t.Errorf("made %d successful list requests, want 3", len(limits))
+	}
+	for _, limit := range limits {
+		if limit != defaultPageSize {
+			t.Errorf("list requests used limits %v, want pages of %d", limits, defaultPageSize)
+			break
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// coalescedGetTimeout bounds a shared GET, since it is not cancelled by any single caller.
+const coalescedGetTimeout = 30 * time.Second
+
+// CoalescingReader is an opt-in read layer over a dynamic client that coalesces identical
+// concurrent GETs into a single API request. When many goroutines ask for the same object
+// within moments of each other, as in a gateway during a thundering herd, only the first
+// request reaches the API server and every caller receives its result.
+//
+// Only Get is coalesced. Writes, lists, and watches should go through Client, which
+// returns the wrapped client unchanged, so they are never merged or delayed.
+type CoalescingReader struct {
+	client dynamic.Interface
+	group  singleflight.Group
+}
+
+// NewCoalescingReader wraps client in a CoalescingReader.
+//
+// Parameters:
+//
+//	client: The dynamic client used for API requests.
+//
+// Returns:
+//
+//	A pointer to a CoalescingReader ready for use by many goroutines.
+func NewCoalescingReader(client dynamic.Interface) *CoalescingReader {
+	return &CoalescingReader{client: client}
+}
+
+// Client returns the wrapped dynamic client, bypassing coalescing. Use it for writes and
+// for reads that must not observe a response shared with other callers.
+func (r *CoalescingReader) Client() dynamic.Interface {
+	return r.client
+}
+
+// Get reads an object, sharing a single API request with any identical Get already in
+// flight. Requests are identical when the resource, namespace, and name match.
+//
+// The shared request is not cancelled when one caller's ctx is; each caller stops waiting
+// when its own ctx is done, while the request itself is bounded by a fixed timeout. Each
+// caller receives its own copy of the object, so callers may modify it freely.
+//
+// Parameters:
+//
+//	ctx: The context bounding how long this caller waits.
+//	gvr: The group/version/resource of the object.
+//	ns: The namespace of the object, or "" for cluster-scoped resources.
+//	name: The name of the object.
+//
+// Returns:
+//
+//	A copy of the object.
+//	The shared request's error, or ctx's error if ctx is done first.
+func (r *CoalescingReader) Get(
+	ctx context.Context,
+	gvr schema.GroupVersionResource,
+	ns, name string,
+) (*unstructured.Unstructured, error) {
+	key := gvr.String() + "/" + ns + "/" + name
+	results := r.group.DoChan(key, func() (any, error) {
+		sharedCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), coalescedGetTimeout)
+		defer cancel()
+		return r.client.Resource(gvr).Namespace(ns).Get(sharedCtx, name, metav1.GetOptions{})
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		obj, ok := result.Val.(*unstructured.Unstructured)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result type %T for %s", result.Val, key)
+		}
+		return obj.DeepCopy(), nil
+	}
+}
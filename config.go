@@ -2,10 +2,17 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/spf13/viper"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 // K8sConfig represents the configuration for a single Kubernetes cluster connection.
@@ -13,8 +20,9 @@ import (
 // including the cluster's name, TLS client configuration, and the API server host address.
 type K8sConfig struct {
 	// Name is a user-defined identifier for the Kubernetes cluster configuration.
-	// This helps in managing configurations for multiple clusters, although currently
-	// only a single cluster configuration ("default") is supported by GetK8sConfigs.
+	// This helps in managing configurations for multiple clusters (see
+	// GetK8sConfigsMulti). A single cluster configuration is named by the
+	// K8S_CLUSTER_NAME environment variable or "default".
 	Name string `mapstructure:"name"`
 
 	// Config holds the TLS client configuration required for secure communication
@@ -22,19 +30,116 @@ type K8sConfig struct {
 	Config TLSClientConfig `mapstructure:"config"`
 
 	// Host is the URL of the Kubernetes API server for the cluster.
-	// Example: "https://192.168.1.100:6443"
+	// Example: "https://192.168.1.100:6443". A host without a scheme defaults to https;
+	// paths and query strings are not allowed.
 	Host string `mapstructure:"host"`
+
+	// Namespace is the namespace clients of this cluster work in by default, returned by
+	// DefaultNamespace. A single cluster configuration may take it from the K8S_NAMESPACE
+	// environment variable. When empty, "default" is used.
+	Namespace string `mapstructure:"namespace"`
+
+	// ContentType selects the wire format used to talk to the API server: ContentTypeJSON,
+	// ContentTypeProtobuf, or ContentTypeCBOR. When empty, JSON is used. CBOR is only used
+	// against Kubernetes 1.32 or newer and falls back to JSON otherwise. With a binary
+	// encoding, reads whose response cannot be decoded are retried once in JSON.
+	ContentType string `mapstructure:"contentType"`
+
+	// ProxyURL is the URL of an HTTP, HTTPS, or SOCKS5 proxy to reach the API server
+	// through, e.g. "http://proxy.corp.example.com:3128". When empty, the standard
+	// HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables are honored.
+	ProxyURL string `mapstructure:"proxyURL"`
+
+	// Impersonate optionally makes every request act as another user, authenticating
+	// with the configured credentials but authorized and audited as that identity. This
+	// lets one privileged credential serve tasks that must each run as a specific user.
+	// When empty, no impersonation headers are sent.
+	Impersonate ImpersonationConfig `mapstructure:"impersonate"`
+
+	// Exec optionally configures an exec credential plugin, such as aws-iam-authenticator,
+	// gke-gcloud-auth-plugin, or kubelogin, that mints short-lived credentials from cloud
+	// IAM. client-go runs the plugin when a request needs credentials and again whenever
+	// the returned credential expires or is rejected, so tokens are refreshed on their own.
+	// When set, it replaces Token, TokenFile, and the client certificate and key, which
+	// must not be set; the CA is still used to verify the server.
+	Exec *ExecConfig `mapstructure:"exec"`
+}
+
+// Exec credential plugin API versions supported by ExecConfig.APIVersion.
+const (
+	ExecAPIVersionV1      = "client.authentication.k8s.io/v1"
+	ExecAPIVersionV1Beta1 = "client.authentication.k8s.io/v1beta1"
+)
+
+// ExecConfig runs an exec credential plugin, as the "exec" section of a kubeconfig user
+// does.
+type ExecConfig struct {
+	// Command is the plugin to run, as a path or a name looked up in PATH,
+	// e.g. "aws" or "gke-gcloud-auth-plugin".
+	Command string `json:"command" mapstructure:"command"`
+
+	// Args are the arguments passed to Command, e.g. ["eks", "get-token", "--cluster-name", "prod"].
+	Args []string `json:"args,omitempty" mapstructure:"args"`
+
+	// Env holds extra environment variables for the plugin, on top of the process's own.
+	Env map[string]string `json:"env,omitempty" mapstructure:"env"`
+
+	// APIVersion is the ExecCredential version the plugin speaks: ExecAPIVersionV1
+	// (Kubernetes 1.22 and newer plugins) or ExecAPIVersionV1Beta1. The v1alpha1 version
+	// is no longer supported by client-go. Empty uses ExecAPIVersionV1.
+	APIVersion string `json:"apiVersion,omitempty" mapstructure:"apiVersion"`
+}
+
+// clientcmdExecConfig converts c to the kubeconfig form used by rest.Config, never
+// allowing the plugin to prompt, since clients built here run unattended.
+func (c *ExecConfig) clientcmdExecConfig() *clientcmdapi.ExecConfig {
+	apiVersion := c.APIVersion
+	if apiVersion == "" {
+		apiVersion = ExecAPIVersionV1
+	}
+
+	env := make([]clientcmdapi.ExecEnvVar, 0, len(c.Env))
+	for _, name := range slices.Sorted(maps.Keys(c.Env)) {
+		env = append(env, clientcmdapi.ExecEnvVar{Name: name, Value: c.Env[name]})
+	}
+
+	return &clientcmdapi.ExecConfig{
+		Command:         c.Command,
+		Args:            c.Args,
+		Env:             env,
+		APIVersion:      apiVersion,
+		InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+	}
+}
+
+// ImpersonationConfig identifies the user requests impersonate. The configured credentials
+// must be granted the "impersonate" verb on the user, groups, and extra fields by RBAC.
+type ImpersonationConfig struct {
+	// UserName is the user to impersonate. It is required when Groups or Extra is set.
+	UserName string `json:"userName,omitempty" mapstructure:"userName"`
+
+	// Groups are the groups to impersonate, sent as Impersonate-Group headers.
+	Groups []string `json:"groups,omitempty" mapstructure:"groups"`
+
+	// Extra holds additional user info to impersonate, such as scopes, sent as
+	// Impersonate-Extra-<key> headers.
+	Extra map[string][]string `json:"extra,omitempty" mapstructure:"extra"`
 }
 
 // TLSClientConfig contains the TLS certificate data required for authenticating
 // with a Kubernetes cluster's API server using client certificates.
 // All certificate data fields (CertData, KeyData, CAData) are expected to be
-// base64 encoded strings.
+// base64 encoded strings, or raw PEM (starting with "-----BEGIN"), which is used as is.
+// Each of them may instead be supplied as a path to a PEM file through the matching file
+// field (CertFile, KeyFile, CAFile), but not both: setting inline data and a file for
+// the same credential is an error.
 type TLSClientConfig struct {
 	// Insecure determines whether the client should skip TLS verification when
 	// connecting to the Kubernetes API server. Setting this to true is generally
 	// discouraged in production environments due to security risks, but can be
-	// useful for development or testing with self-signed certificates.
+	// useful for development or testing with self-signed certificates. When set without
+	// a token, CertData, KeyData, or their file fields, no client certificate is
+	// required and the client connects anonymously.
 	Insecure bool `json:"insecure"`
 
 	// CertData contains the base64 encoded client certificate data. This certificate
@@ -47,19 +152,116 @@ type TLSClientConfig struct {
 
 	// CAData contains the base64 encoded certificate authority (CA) data. This CA
 	// certificate is used by the client to verify the identity of the Kubernetes
-	// API server.
+	// API server. It must be empty when Insecure is set, and may be empty for a server
+	// with a publicly trusted certificate (see K8sConfig.Validate).
 	CAData string `json:"caData"`
+
+	// AppendSystemCAs makes the API server certificate trusted if it chains to either the
+	// CA in CAData or CAFile or one of the system roots, rather than to the CA alone, for
+	// a server whose certificate may be issued by a private or a public CA. Like PKCS11,
+	// it replaces client-go's TLS handling with a custom transport, so it cannot be
+	// combined with Exec, and the client certificate and key files are read only once.
+	// It has no effect without a CA, when the system roots are used anyway.
+	AppendSystemCAs bool `json:"appendSystemCAs,omitempty"`
+
+	// ServerName optionally overrides the name the API server's certificate is verified
+	// against, for connecting by IP address (https://10.0.0.5:6443) to a server whose
+	// certificate is issued for a DNS name. It is also sent as the TLS SNI. When empty,
+	// the certificate is verified against the host name in the API server URL.
+	ServerName string `json:"serverName,omitempty"`
+
+	// KeyPassphrase optionally holds the passphrase the client private key (from KeyData or
+	// KeyFile) is encrypted with. Both legacy encrypted PEM and encrypted PKCS#8 keys are
+	// supported. Leave it empty for unencrypted keys.
+	KeyPassphrase string `json:"keyPassphrase,omitempty"`
+
+	// CertFile is the path to a PEM encoded client certificate file, used instead of CertData.
+	CertFile string `json:"certFile,omitempty"`
+
+	// KeyFile is the path to a PEM encoded client private key file, used instead of KeyData.
+	KeyFile string `json:"keyFile,omitempty"`
+
+	// CAFile is the path to a PEM encoded CA certificate file, used instead of CAData.
+	CAFile string `json:"caFile,omitempty"`
+
+	// Token is a bearer token, such as a service account token, used to authenticate
+	// instead of a client certificate. When Token or TokenFile is set, CertData, KeyData,
+	// their file fields, and PKCS11 are not required and are ignored if present. CAData
+	// or CAFile is still used to verify the API server.
+	Token string `json:"token,omitempty"`
+
+	// TokenFile is the path to a file containing a bearer token, used when Token is empty.
+	// The file is re-read periodically, so rotated tokens are picked up automatically.
+	TokenFile string `json:"tokenFile,omitempty"`
+
+	// PKCS11 optionally configures a PKCS#11 token (HSM, smart card) holding the client
+	// private key. When set, KeyData is not required: the key never leaves the token and
+	// all signing operations during the TLS handshake are delegated to it. CertData must
+	// still contain the matching client certificate. Using this requires a binary built
+	// with the "pkcs11" build tag.
+	PKCS11 *PKCS11Config `json:"pkcs11,omitempty"`
+}
+
+// PKCS11Config identifies a private key stored on a PKCS#11 token.
+type PKCS11Config struct {
+	// ModulePath is the filesystem path of the PKCS#11 module (shared library) provided
+	// by the token vendor, e.g. "/usr/lib/softhsm/libsofthsm2.so".
+	ModulePath string `json:"modulePath"`
+
+	// TokenLabel is the label of the token holding the key.
+	TokenLabel string `json:"tokenLabel"`
+
+	// Pin is the user PIN used to log in to the token.
+	Pin string `json:"pin"`
+
+	// KeyID is the CKA_ID of the key pair on the token, hex encoded as printed by
+	// pkcs11-tool --list-objects, e.g. "01ab". At least one of KeyID or KeyLabel must
+	// be set.
+	KeyID string `json:"keyId,omitempty"`
+
+	// KeyLabel is the CKA_LABEL of the key pair on the token.
+	KeyLabel string `json:"keyLabel,omitempty"`
 }
 
 // KubeConfig represents the structure expected within the K8S_CONFIG environment
 // variable when it contains JSON formatted configuration. Specifically, it looks
 // for a 'tlsClientConfig' key holding the TLS configuration details.
+//
+// When K8S_CONFIG holds a JSON array of clusters (see GetK8sConfigsMulti), each
+// element is a KubeConfig that also names the cluster and its API server host.
 type KubeConfig struct {
+	// Name identifies the cluster. It is required for every entry of a multi-cluster
+	// array with more than one entry, and must be unique.
+	Name string `json:"name,omitempty"`
+
+	// Host is the URL of the cluster's API server. It is required for every entry of a
+	// multi-cluster array; a single cluster may instead take it from K8S_HOST.
+	Host string `json:"host,omitempty"`
+
+	// Namespace is the cluster's default namespace (see K8sConfig.Namespace). A single
+	// cluster may instead take it from K8S_NAMESPACE.
+	Namespace string `json:"namespace,omitempty"`
+
+	// ContentType is the cluster's wire format (see K8sConfig.ContentType).
+	ContentType string `json:"contentType,omitempty"`
+
+	// ProxyURL is the proxy the cluster is reached through (see K8sConfig.ProxyURL).
+	ProxyURL string `json:"proxyURL,omitempty"`
+
+	// Impersonate is the identity requests act as (see K8sConfig.Impersonate).
+	Impersonate ImpersonationConfig `json:"impersonate,omitzero"`
+
+	// Exec is the exec credential plugin authenticating requests (see K8sConfig.Exec).
+	Exec *ExecConfig `json:"exec,omitempty"`
+
 	// TLSClientConfig embeds the TLS configuration details (certificates, keys, CA)
-	// needed for establishing a secure connection.
+	// needed for establishing a secure connection. It may be empty when Exec is set.
 	TLSClientConfig TLSClientConfig `json:"tlsClientConfig"`
 }
 
+// defaultClusterName is the name given to a single cluster configuration that has none.
+const defaultClusterName = "default"
+
 // GetK8sConfigs retrieves Kubernetes cluster configuration from environment variables.
 // It expects the TLS client configuration (certificates, keys, CA) to be provided
 // as a JSON string within the 'K8S_CONFIG' environment variable, and the API server
@@ -71,41 +273,628 @@ type KubeConfig struct {
 // Example K8S_CONFIG value:
 // '{"tlsClientConfig":{"insecure":false,"certData":"LS0t...","keyData":"LS0t...","caData":"LS0t..."}}'
 //
+// When K8S_CONFIG is unset, the same JSON is read from the file named by the
+// 'K8S_CONFIG_FILE' environment variable instead, which avoids environment variable
+// length limits for large certificates. K8S_CONFIG takes precedence when both are set.
+//
+// Beside 'tlsClientConfig', the JSON object may set 'contentType', 'proxyURL',
+// 'impersonate' (with 'userName', 'groups', and 'extra'), and 'exec' (with 'command',
+// 'args', 'env', and 'apiVersion'); they populate the K8sConfig fields of the same name.
+// When 'exec' is set, 'tlsClientConfig' may be empty.
+//
 // The 'K8S_HOST' environment variable should contain the full URL of the Kubernetes
-// API server.
+// API server. When it is unset inside a pod, the API server address Kubernetes injects
+// through KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT is used instead.
 // Example K8S_HOST value:
 // 'https://my-kube-api.example.com:6443'
 //
-// It returns a K8sConfig struct populated with the retrieved configuration data
-// and a default name "default". If either environment variable is missing or if
-// the JSON in K8S_CONFIG cannot be unmarshalled, it returns an error.
+// The optional 'K8S_CLUSTER_NAME' environment variable sets the Name of the returned
+// configuration, which is used in log and error messages. When it is unset the name
+// "default" is used.
+//
+// If K8S_CONFIG holds a multi-cluster array instead (see GetK8sConfigsMulti), the
+// entry named "default" is returned, or the first entry if none has that name.
+//
+// To read differently named variables, use GetK8sConfigsWithPrefix.
+//
+// It returns a K8sConfig struct populated with the retrieved configuration data.
+// If either required environment variable is missing or if the JSON in K8S_CONFIG
+// cannot be unmarshalled, it returns an error.
 func GetK8sConfigs() (K8sConfig, error) {
-	viper.AutomaticEnv() // Automatically read environment variables
+	return GetK8sConfigsWithPrefix(defaultEnvPrefix)
+}
 
-	config := os.Getenv("K8S_CONFIG")
+// GetK8sConfigsWithPrefix is GetK8sConfigs reading the environment variables named with
+// prefix instead of "K8S": {PREFIX}_CONFIG, {PREFIX}_CONFIG_FILE, {PREFIX}_HOST, and
+// {PREFIX}_CLUSTER_NAME. This lets each service namespace its configuration and avoids
+// collisions with other tools that use K8S_HOST.
+//
+// Parameters:
+//
+//	prefix: The environment variable prefix, e.g. "BILLING_K8S". It is upper-cased.
+//
+// Returns:
+//
+//	The cluster configuration, as for GetK8sConfigs.
+//	An error naming the prefixed variable that is missing or invalid.
+func GetK8sConfigsWithPrefix(prefix string) (K8sConfig, error) {
+	configs, err := GetK8sConfigsMultiWithPrefix(prefix)
+	if err != nil {
+		return K8sConfig{}, err
+	}
 
-	if config == "" {
-		return K8sConfig{}, fmt.Errorf("K8S_CONFIG environment variable is not set")
+	for _, config := range configs {
+		if config.Name == defaultClusterName {
+			return config, nil
+		}
 	}
+	return configs[0], nil
+}
 
-	var tlsConfig TLSClientConfig
-	var kubeConfig KubeConfig
+// GetK8sConfigsMulti retrieves the configuration of one or more Kubernetes clusters from
+// environment variables, so a whole fleet can be configured through K8S_CONFIG.
+//
+// K8S_CONFIG may hold either the single JSON object accepted by GetK8sConfigs, or a JSON
+// array of such objects where each entry also carries a 'name' and a 'host':
+// '[{"name":"prod","host":"https://prod:6443","tlsClientConfig":{...}},{"name":"staging",...}]'
+//
+// A single object is read exactly as GetK8sConfigs reads it: its host may come from
+// K8S_HOST and its name from K8S_CLUSTER_NAME. Array entries must each name their host,
+// and must each have a unique name, except that the name of a single-entry array
+// defaults like a single object.
+//
+// Returns:
+//
+//	The cluster configurations, in the order they appear in K8S_CONFIG. Never empty.
+//	An error if neither K8S_CONFIG nor K8S_CONFIG_FILE is set (wrapping
+//	ErrNoClustersConfigured, as does an empty array), the file cannot be read,
+//	the configuration is not valid JSON, is neither an object nor an array of objects,
+//	has an entry without a non-empty tlsClientConfig object or without a host, or has a
+//	missing or duplicate name.
+func GetK8sConfigsMulti() ([]K8sConfig, error) {
+	return GetK8sConfigsMultiWithPrefix(defaultEnvPrefix)
+}
+
+// GetK8sConfigsMultiWithPrefix is GetK8sConfigsMulti reading the environment variables
+// named with prefix instead of "K8S", like GetK8sConfigsWithPrefix.
+func GetK8sConfigsMultiWithPrefix(prefix string) ([]K8sConfig, error) {
+	env := newConfigEnv(prefix)
+
+	config, err := env.readConfig()
+	if err != nil {
+		return nil, err
+	}
 
-	// Try unmarshalling the JSON configuration from the environment variable
-	if err := json.Unmarshal([]byte(config), &kubeConfig); err == nil {
-		tlsConfig = kubeConfig.TLSClientConfig
-	} else {
-		return K8sConfig{}, fmt.Errorf("failed to unmarshal: %w", err)
+	kind, err := jsonKind([]byte(config))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", env.name("CONFIG"), err)
 	}
 
-	k8sConfig := K8sConfig{
-		Name:   "default",
-		Config: tlsConfig,
-		Host:   os.Getenv("K8S_HOST"),
+	switch kind {
+	case "array":
+		return env.k8sConfigs([]byte(config))
+	case "object":
+	default:
+		return nil, fmt.Errorf("%s must hold a JSON object or an array of objects, got a JSON %s",
+			env.name("CONFIG"), kind)
 	}
+
+	// A single object keeps its original meaning, with host and name from the environment
+	kubeConfig, err := decodeKubeConfig([]byte(config), env.name("CONFIG"))
+	if err != nil {
+		return nil, err
+	}
+
+	k8sConfig := env.k8sConfig(kubeConfig)
 	if k8sConfig.Host == "" {
-		return K8sConfig{}, fmt.Errorf("K8S_HOST environment variable is not set")
+		return nil, fmt.Errorf("%s environment variable is not set and not running in a cluster",
+			env.name("HOST"))
+	}
+	if k8sConfig.Host, err = normalizeHost(k8sConfig.Host, k8sConfig.Name); err != nil {
+		return nil, err
+	}
+	return []K8sConfig{k8sConfig}, nil
+}
+
+// k8sConfigs converts a multi-cluster array read from {PREFIX}_CONFIG, checking that every
+// entry is an object with a host and, if there are several, a unique name.
+func (env configEnv) k8sConfigs(config []byte) ([]K8sConfig, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(config, &entries); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", env.name("CONFIG"), err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: %s contains an empty array, at least one cluster must be configured",
+			ErrNoClustersConfigured, env.name("CONFIG"))
+	}
+
+	configs := make([]K8sConfig, 0, len(entries))
+	seen := map[string]int{}
+	for i, entry := range entries {
+		kubeConfig, err := decodeKubeConfig(entry, fmt.Sprintf("%s entry %d", env.name("CONFIG"), i))
+		if err != nil {
+			return nil, err
+		}
+		if kubeConfig.Name == "" && len(entries) > 1 {
+			return nil, fmt.Errorf("%s entry %d has no name", env.name("CONFIG"), i)
+		}
+		if kubeConfig.Host == "" {
+			return nil, fmt.Errorf("%s entry %d (%s) has no host", env.name("CONFIG"), i, kubeConfig.Name)
+		}
+
+		k8sConfig := env.k8sConfig(kubeConfig)
+		if k8sConfig.Host, err = normalizeHost(k8sConfig.Host, k8sConfig.Name); err != nil {
+			return nil, err
+		}
+		if first, ok := seen[k8sConfig.Name]; ok {
+			return nil, fmt.Errorf("%s entries %d and %d have the same name %q",
+				env.name("CONFIG"), first, i, k8sConfig.Name)
+		}
+		seen[k8sConfig.Name] = i
+		configs = append(configs, k8sConfig)
+	}
+
+	return configs, nil
+}
+
+// decodeKubeConfig decodes one cluster's JSON configuration, checking its shape first so a
+// malformed value is reported where it is, rather than as missing credentials later. where
+// names the value in errors, e.g. "K8S_CONFIG" or "K8S_CONFIG entry 1".
+func decodeKubeConfig(data []byte, where string) (KubeConfig, error) {
+	fields, err := decodeJSONObject(data, where)
+	if err != nil {
+		return KubeConfig{}, err
+	}
+
+	tlsClientConfig, ok := fields["tlsClientConfig"]
+	if !ok {
+		return KubeConfig{}, fmt.Errorf("%s has no tlsClientConfig key", where)
+	}
+	tlsFields, err := decodeJSONObject(tlsClientConfig, where+" tlsClientConfig")
+	if err != nil {
+		return KubeConfig{}, err
+	}
+	// An exec plugin provides the credentials, so a server with a public certificate needs no TLS settings
+	if _, hasExec := fields["exec"]; len(tlsFields) == 0 && !hasExec {
+		return KubeConfig{}, fmt.Errorf("%s tlsClientConfig is empty, it needs credentials such as "+
+			"certData and keyData, a token, or an exec plugin", where)
+	}
+
+	var kubeConfig KubeConfig
+	if err := json.Unmarshal(data, &kubeConfig); err != nil {
+		return KubeConfig{}, fmt.Errorf("failed to unmarshal %s: %w", where, err)
+	}
+	return kubeConfig, nil
+}
+
+// decodeJSONObject decodes data into its fields, failing with an error naming it as where
+// if it is not a JSON object.
+func decodeJSONObject(data []byte, where string) (map[string]json.RawMessage, error) {
+	kind, err := jsonKind(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", where, err)
+	}
+	if kind != "object" {
+		return nil, fmt.Errorf("%s must be a JSON object, got a JSON %s", where, kind)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", where, err)
+	}
+	return fields, nil
+}
+
+// jsonKind returns the kind of JSON value data holds: "object", "array", "string",
+// "number", "boolean", or "null".
+func jsonKind(data []byte) (string, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", err
+	}
+
+	switch value.(type) {
+	case map[string]any:
+		return "object", nil
+	case []any:
+		return "array", nil
+	case string:
+		return "string", nil
+	case float64:
+		return "number", nil
+	case bool:
+		return "boolean", nil
+	default:
+		return "null", nil
+	}
+}
+
+// ErrNoClustersConfigured is returned, wrapped, by GetK8sConfigs, GetK8sConfigsMulti, and
+// GetK8sConfigByName when neither K8S_CONFIG nor K8S_CONFIG_FILE is set, or K8S_CONFIG
+// holds an empty array. Check for it with errors.Is.
+var ErrNoClustersConfigured = errors.New("no Kubernetes clusters configured")
+
+// ClusterNotFoundError is returned by GetK8sConfigByName when no configured cluster has
+// the requested name. Check for it with errors.As.
+type ClusterNotFoundError struct {
+	// Name is the cluster name that was looked up.
+	Name string
+
+	// Available lists the names of the configured clusters, in configuration order.
+	Available []string
+}
+
+// Error implements the error interface.
+func (e *ClusterNotFoundError) Error() string {
+	return fmt.Sprintf("no cluster named %q is configured, available clusters are %s",
+		e.Name, strings.Join(e.Available, ", "))
+}
+
+// GetK8sConfigByName reads the configured clusters like GetK8sConfigsMulti and returns the
+// one named name, so a process connected to several clusters can pick one by identifier
+// at runtime. Names are compared case-sensitively.
+//
+// Parameters:
+//
+//	name: The name of the cluster, as set by its "name" field or K8S_CLUSTER_NAME.
+//
+// Returns:
+//
+//	The configuration of the named cluster.
+//	An error wrapping ErrNoClustersConfigured if no clusters are configured, a
+//	*ClusterNotFoundError listing the available names if none has that name, or
+//	another error if the configuration cannot be read.
+func GetK8sConfigByName(name string) (K8sConfig, error) {
+	return GetK8sConfigByNameWithPrefix(defaultEnvPrefix, name)
+}
+
+// GetK8sConfigByNameWithPrefix is GetK8sConfigByName reading the environment variables
+// named with prefix instead of "K8S", like GetK8sConfigsWithPrefix.
+func GetK8sConfigByNameWithPrefix(prefix, name string) (K8sConfig, error) {
+	configs, err := GetK8sConfigsMultiWithPrefix(prefix)
+	if err != nil {
+		return K8sConfig{}, err
+	}
+
+	available := make([]string, 0, len(configs))
+	for _, config := range configs {
+		if config.Name == name {
+			return config, nil
+		}
+		available = append(available, config.Name)
+	}
+	return K8sConfig{}, &ClusterNotFoundError{Name: name, Available: available}
+}
+
+// defaultEnvPrefix is the prefix of the environment variables read by GetK8sConfigs.
+const defaultEnvPrefix = "K8S"
+
+// configEnv reads the configuration environment variables sharing one prefix.
+type configEnv struct {
+	viper  *viper.Viper
+	prefix string
+}
+
+// newConfigEnv returns a configEnv that reads {PREFIX}_* variables through viper's
+// environment binding.
+func newConfigEnv(prefix string) configEnv {
+	v := viper.New()
+	v.SetEnvPrefix(prefix)
+	v.AutomaticEnv()
+	return configEnv{viper: v, prefix: strings.ToUpper(prefix)}
+}
+
+// name returns the full name of the variable with the given suffix, e.g. "K8S_HOST".
+func (env configEnv) name(suffix string) string {
+	if env.prefix == "" {
+		return suffix
+	}
+	return env.prefix + "_" + suffix
+}
+
+// get returns the value of the variable with the given suffix.
+func (env configEnv) get(suffix string) string {
+	return env.viper.GetString(strings.ToLower(suffix))
+}
+
+// readConfig returns the trimmed value of {PREFIX}_CONFIG or, if it is unset, the
+// trimmed contents of the file named by {PREFIX}_CONFIG_FILE.
+func (env configEnv) readConfig() (string, error) {
+	if config := strings.TrimSpace(env.get("CONFIG")); config != "" {
+		return config, nil
+	}
+
+	configFile := env.get("CONFIG_FILE")
+	if configFile == "" {
+		return "", fmt.Errorf("%w: neither the %s nor the %s environment variable is set",
+			ErrNoClustersConfigured, env.name("CONFIG"), env.name("CONFIG_FILE"))
+	}
+
+	contents, err := os.ReadFile(configFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s %s: %w", env.name("CONFIG_FILE"), configFile, err)
+	}
+	config := strings.TrimSpace(string(contents))
+	if config == "" {
+		return "", fmt.Errorf("%s %s is empty", env.name("CONFIG_FILE"), configFile)
+	}
+	return config, nil
+}
+
+// inClusterCAFile holds the CA of the cluster's API server, mounted into every pod
+// alongside its service account token. It is a variable so tests can point it elsewhere.
+var inClusterCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+
+// k8sConfig converts a KubeConfig to a K8sConfig, falling back to {PREFIX}_CLUSTER_NAME
+// (or "default") for an unnamed cluster, to {PREFIX}_NAMESPACE for a cluster without a
+// namespace, and to {PREFIX}_HOST for a cluster without a host, or failing that to the
+// in-cluster API server address (see inClusterServiceAddress). With the in-cluster
+// address, a cluster that sets neither a CA nor insecure verifies the server against the
+// service account CA (inClusterCAFile), if it is mounted.
+func (env configEnv) k8sConfig(kubeConfig KubeConfig) K8sConfig {
+	name := kubeConfig.Name
+	if name == "" {
+		name = env.get("CLUSTER_NAME")
+	}
+	if name == "" {
+		name = defaultClusterName
+	}
+
+	host := kubeConfig.Host
+	if host == "" {
+		host = env.get("HOST")
+	}
+	// A pod is told its API server's address even when the external-style config is used
+	tlsConfig := kubeConfig.TLSClientConfig
+	if address, ok := inClusterServiceAddress(); host == "" && ok {
+		host = "https://" + address
+		Logger.Info("Host is not set, using the in-cluster API server address",
+			"variable", env.name("HOST"), "host", host)
+
+		// The in-cluster address is an IP, which Validate only accepts with a CA
+		if tlsConfig.CAData == "" && tlsConfig.CAFile == "" && !tlsConfig.Insecure {
+			if _, err := os.Stat(inClusterCAFile); err == nil {
+				tlsConfig.CAFile = inClusterCAFile
+			}
+		}
+	}
+
+	namespace := kubeConfig.Namespace
+	if namespace == "" {
+		namespace = env.get("NAMESPACE")
+	}
+
+	return K8sConfig{
+		Name:        name,
+		Config:      tlsConfig,
+		Host:        host,
+		Namespace:   namespace,
+		ContentType: kubeConfig.ContentType,
+		ProxyURL:    kubeConfig.ProxyURL,
+		Impersonate: kubeConfig.Impersonate,
+		Exec:        kubeConfig.Exec,
+	}
+}
+
+// inClusterServiceAddress returns the host:port of the API server from the
+// KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT environment variables that
+// Kubernetes injects into every pod, and whether both are set.
+func inClusterServiceAddress() (string, bool) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return "", false
+	}
+	return net.JoinHostPort(host, port), true
+}
+
+// Validate checks the configuration for missing, malformed, or contradictory settings
+// before any client is built, and reports every problem found at once rather than only
+// the first, so a new cluster can be set up in one pass. BuildRestConfig calls it, so
+// every external constructor does too.
+//
+// Host must be an http or https URL without a path, or a host[:port] that defaults to
+// https (see BuildRestConfig). Each required credential (see
+// BuildRestConfig) must be present exactly once, as inline data that decodes or as a file
+// that exists.
+//
+// A CA certificate (CAData or CAFile) cannot be combined with Insecure, since the CA would
+// be silently ignored. When Insecure is false and no CA is given, the system trust store
+// is used to verify the server, which only works for a host name with a certificate from
+// a publicly trusted CA; a CA is therefore required for an IP address, localhost, or an
+// internal name such as a single-label or ".local" name. When ServerName is set, it is
+// checked instead of the host. With Insecure, the client certificate and key may also be
+// omitted together, but a certificate without its key (or the reverse) is still an error.
+//
+// A ProxyURL, if set, must be an absolute URL with an http, https, or socks5 scheme, and
+// impersonated groups or extra fields require an impersonated user name. An exec plugin
+// needs a command and a supported API version, and excludes other client credentials.
+// ContentType must be empty or one of the ContentType constants.
+//
+// Returns:
+//
+//	nil if the configuration is valid.
+//	Otherwise the errors.Join of one error per problem, each naming the cluster.
+func (c K8sConfig) Validate() error {
+	var errs []error
+
+	host, err := normalizeHost(c.Host, c.Name)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := parseProxyURL(c.ProxyURL, c.Name); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Impersonate.UserName == "" && (len(c.Impersonate.Groups) > 0 || len(c.Impersonate.Extra) > 0) {
+		errs = append(errs, fmt.Errorf("cluster %s impersonates groups or extra fields without a user name", c.Name))
+	}
+	switch c.ContentType {
+	case "", ContentTypeJSON, ContentTypeProtobuf, ContentTypeCBOR:
+	default:
+		errs = append(errs, fmt.Errorf("cluster %s sets unsupported content type %q; use %s, %s, or %s",
+			c.Name, c.ContentType, ContentTypeJSON, ContentTypeProtobuf, ContentTypeCBOR))
+	}
+
+	tlsConfig := c.Config
+	useToken := tlsConfig.Token != "" || tlsConfig.TokenFile != ""
+	if c.Exec != nil {
+		errs = append(errs, validateExec(c.Exec, c.Name)...)
+		if useToken || tlsConfig.CertData != "" || tlsConfig.CertFile != "" {
+			errs = append(errs, fmt.Errorf("cluster %s sets an exec plugin together with a token or client "+
+				"certificate; set only one", c.Name))
+		}
+	}
+	useClientCert := c.usesClientCertificate()
+	if useClientCert {
+		if _, _, err := resolveCredential(tlsConfig.CertData, tlsConfig.CertFile, "certificate", c.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if useClientCert && tlsConfig.PKCS11 == nil {
+		if _, _, err := resolveCredential(tlsConfig.KeyData, tlsConfig.KeyFile, "key", c.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	hasCA := tlsConfig.CAData != "" || tlsConfig.CAFile != ""
+	if hasCA {
+		if _, _, err := resolveCredential(tlsConfig.CAData, tlsConfig.CAFile, "CA", c.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if tlsConfig.AppendSystemCAs && c.Exec != nil {
+		errs = append(errs, fmt.Errorf("cluster %s sets appendSystemCAs together with an exec plugin, "+
+			"which client-go cannot combine with a custom transport", c.Name))
+	}
+	if tlsConfig.Insecure && hasCA {
+		errs = append(errs, fmt.Errorf("cluster %s sets insecure together with CA data, which would be ignored; "+
+			"set only one", c.Name))
+	}
+	// The certificate is verified against ServerName rather than the URL's host when set
+	verifiedHost := host
+	if tlsConfig.ServerName != "" {
+		verifiedHost = "https://" + tlsConfig.ServerName
+	}
+	if !tlsConfig.Insecure && !hasCA && !hostHasPublicCertificate(verifiedHost) {
+		errs = append(errs, fmt.Errorf("no CA data provided for cluster %s, required to verify host %s "+
+			"which is not served with a publicly trusted certificate", c.Name, verifiedHost))
+	}
+
+	return errors.Join(errs...)
+}
+
+// usesClientCertificate reports whether the client authenticates with a client
+// certificate. That is the case unless a bearer token or exec plugin is used, or Insecure
+// is set without any certificate or key, which connects anonymously to a development
+// cluster such as kind or minikube.
+func (c K8sConfig) usesClientCertificate() bool {
+	tlsConfig := c.Config
+	if tlsConfig.Token != "" || tlsConfig.TokenFile != "" || c.Exec != nil {
+		return false
+	}
+	return !tlsConfig.Insecure || tlsConfig.CertData != "" || tlsConfig.CertFile != "" ||
+		tlsConfig.KeyData != "" || tlsConfig.KeyFile != "" || tlsConfig.PKCS11 != nil
+}
+
+// validateExec checks that an exec plugin has a command and a supported API version.
+func validateExec(execConfig *ExecConfig, clusterName string) []error {
+	var errs []error
+	if execConfig.Command == "" {
+		errs = append(errs, fmt.Errorf("no exec plugin command provided for cluster %s", clusterName))
+	}
+	switch execConfig.APIVersion {
+	case "", ExecAPIVersionV1, ExecAPIVersionV1Beta1:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported exec plugin apiVersion %q for cluster %s, must be %s or %s",
+			execConfig.APIVersion, clusterName, ExecAPIVersionV1, ExecAPIVersionV1Beta1))
+	}
+	return errs
+}
+
+// normalizeHost checks an API server host and returns it in the canonical form
+// scheme://host[:port]. A host without a scheme, such as "my-kube-api.example.com:6443",
+// defaults to https. Schemes other than http and https, paths, query strings, fragments,
+// and user info are rejected, since client-go would otherwise build a config that only
+// fails later with an opaque connection error.
+func normalizeHost(host, clusterName string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("no host provided for cluster %s", clusterName)
+	}
+
+	withScheme := host
+	if !strings.Contains(host, "://") {
+		withScheme = "https://" + host
+	}
+	parsed, err := url.Parse(withScheme)
+	if err != nil {
+		return "", fmt.Errorf("invalid host %q for cluster %s: %w", host, clusterName, err)
+	}
+
+	var problem string
+	switch {
+	case parsed.Scheme != "http" && parsed.Scheme != "https":
+		problem = "the scheme must be http or https"
+	case parsed.Host == "":
+		problem = "no host name"
+	case parsed.Path != "" && parsed.Path != "/":
+		problem = "paths are not supported"
+	case parsed.RawQuery != "" || parsed.Fragment != "" || strings.HasSuffix(withScheme, "?"):
+		problem = "query strings and fragments are not supported"
+	case parsed.User != nil:
+		problem = "user info is not supported"
+		host = parsed.Redacted() // never echo a password
+	}
+	if problem != "" {
+		return "", fmt.Errorf("invalid host %q for cluster %s: %s, expected e.g. https://10.0.0.5:6443",
+			host, clusterName, problem)
+	}
+
+	return parsed.Scheme + "://" + parsed.Host, nil
+}
+
+// privateHostSuffixes are DNS suffixes reserved for private use, which a publicly trusted
+// CA never issues certificates for.
+var privateHostSuffixes = []string{".local", ".localhost", ".internal", ".home.arpa", ".svc", ".cluster.local"}
+
+// hostHasPublicCertificate reports whether the API server URL host may be served with a
+// certificate from a publicly trusted CA: a fully qualified DNS name outside the private
+// suffixes. IP addresses, localhost, and single-label names never are.
+func hostHasPublicCertificate(host string) bool {
+	parsed, err := url.Parse(host)
+	if err != nil || parsed.Hostname() == "" {
+		return false
+	}
+
+	hostname := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if net.ParseIP(hostname) != nil || hostname == "localhost" || !strings.Contains(hostname, ".") {
+		return false
+	}
+	for _, suffix := range privateHostSuffixes {
+		if strings.HasSuffix(hostname, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseProxyURL parses a K8sConfig.ProxyURL, returning nil for an empty one.
+func parseProxyURL(proxyURL, clusterName string) (*url.URL, error) {
+	if proxyURL == "" {
+		return nil, nil
+	}
+
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL for cluster %s: %w", clusterName, err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q for cluster %s: scheme must be http, https, or socks5",
+			parsed.Redacted(), clusterName)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q for cluster %s: no host", parsed.Redacted(), clusterName)
 	}
 
-	return k8sConfig, nil
+	return parsed, nil
 }
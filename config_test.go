@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetK8sConfigsClientFields(t *testing.T) {
+	t.Setenv("K8S_HOST", "https://kube.example.com:6443")
+	t.Setenv("K8S_CONFIG_FILE", "")
+	t.Setenv("K8S_CONFIG", `{
+		"contentType": "application/vnd.kubernetes.protobuf",
+		"proxyURL": "http://proxy.example.com:3128",
+		"impersonate": {"userName": "deployer", "groups": ["ops"], "extra": {"scopes": ["view"]}},
+		"exec": {
+			"command": "aws",
+			"args": ["eks", "get-token"],
+			"env": {"AWS_PROFILE": "prod"},
+			"apiVersion": "client.authentication.k8s.io/v1"
+		},
+		"tlsClientConfig": {}
+	}`)
+
+	config, err := GetK8sConfigs()
+	if err != nil {
+		t.Fatalf("GetK8sConfigs() error = %v", err)
+	}
+
+	if config.ContentType != ContentTypeProtobuf {
+		t.Errorf("ContentType = %q, want %q", config.ContentType, ContentTypeProtobuf)
+	}
+	if config.ProxyURL != "http://proxy.example.com:3128" {
+		t.Errorf("ProxyURL = %q, want http://proxy.example.com:3128", config.ProxyURL)
+	}
+
+	wantImpersonate := ImpersonationConfig{
+		UserName: "deployer",
+		Groups:   []string{"ops"},
+		Extra:    map[string][]string{"scopes": {"view"}},
+	}
+	if !reflect.DeepEqual(config.Impersonate, wantImpersonate) {
+		t.Errorf("Impersonate = %+v, want %+v", config.Impersonate, wantImpersonate)
+	}
+
+	wantExec := &ExecConfig{
+		Command:    "aws",
+		Args:       []string{"eks", "get-token"},
+		Env:        map[string]string{"AWS_PROFILE": "prod"},
+		APIVersion: "client.authentication.k8s.io/v1",
+	}
+	if !reflect.DeepEqual(config.Exec, wantExec) {
+		t.Errorf("Exec = %+v, want %+v", config.Exec, wantExec)
+	}
+}
+
+func TestGetK8sConfigsEmptyTLSClientConfig(t *testing.T) {
+	t.Setenv("K8S_HOST", "https://kube.example.com:6443")
+	t.Setenv("K8S_CONFIG_FILE", "")
+	t.Setenv("K8S_CONFIG", `{"tlsClientConfig": {}}`)
+
+	if _, err := GetK8sConfigs(); err == nil {
+		t.Error("GetK8sConfigs() error = nil, want an error for an empty tlsClientConfig without exec")
+	}
+}
+
+func TestGetK8sConfigsInClusterCA(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, []byte("-----BEGIN CERTIFICATE-----\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defaultCAFile := inClusterCAFile
+	inClusterCAFile = caFile
+	t.Cleanup(func() { inClusterCAFile = defaultCAFile })
+
+	tests := []struct {
+		name       string
+		host       string
+		config     string
+		wantCAFile string
+	}{
+		{name: "in-cluster host", config: `{"tlsClientConfig":{"token":"abc"}}`, wantCAFile: caFile},
+		{name: "explicit CA", config: `{"tlsClientConfig":{"token":"abc","caFile":"/etc/ca.crt"}}`,
+			wantCAFile: "/etc/ca.crt"},
+		{name: "insecure", config: `{"tlsClientConfig":{"token":"abc","insecure":true}}`},
+		{name: "explicit host", host: "https://10.0.0.1:443", config: `{"tlsClientConfig":{"token":"abc"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+			t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+			t.Setenv("K8S_HOST", tt.host)
+			t.Setenv("K8S_CONFIG_FILE", "")
+			t.Setenv("K8S_CONFIG", tt.config)
+
+			config, err := GetK8sConfigs()
+			if err != nil {
+				t.Fatalf("GetK8sConfigs() error = %v", err)
+			}
+			if config.Config.CAFile != tt.wantCAFile {
+				t.Errorf("CAFile = %q, want %q", config.Config.CAFile, tt.wantCAFile)
+			}
+		})
+	}
+}
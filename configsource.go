@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ConfigSource loads the configuration of an external cluster, so credentials can come
+// from a secrets manager such as Vault or AWS Secrets Manager instead of environment
+// variables, without changing how clients are built. Load is called each time a client is
+// built from the source, so a source backed by rotating credentials should fetch them
+// afresh, or from a cache it keeps current.
+type ConfigSource interface {
+	// Load returns the cluster configuration, bounded by ctx.
+	Load(ctx context.Context) (K8sConfig, error)
+}
+
+// ConfigSourceFunc adapts a function to a ConfigSource.
+type ConfigSourceFunc func(ctx context.Context) (K8sConfig, error)
+
+// Load calls f.
+func (f ConfigSourceFunc) Load(ctx context.Context) (K8sConfig, error) {
+	return f(ctx)
+}
+
+// EnvConfigSource is the ConfigSource reading environment variables, as GetK8sConfigs
+// does. Its zero value reads the K8S_* variables, like CreateKubeRestClient outside a
+// cluster.
+type EnvConfigSource struct {
+	// Prefix optionally replaces "K8S" in the variable names, as for
+	// GetK8sConfigsWithPrefix.
+	Prefix string
+}
+
+// Load reads the configuration with GetK8sConfigsWithPrefix. Reading environment
+// variables does not block, so ctx is not used.
+func (s EnvConfigSource) Load(_ context.Context) (K8sConfig, error) {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = defaultEnvPrefix
+	}
+	return GetK8sConfigsWithPrefix(prefix)
+}
+
+// CreateExternalClusterKubeRestClientFromSource loads the cluster configuration from
+// source and connects to it with CreateExternalClusterKubeRestClientContext.
+//
+// Parameters:
+//
+//	ctx: The context bounding both the Load call and the connection check.
+//	source: Where the configuration comes from, e.g. EnvConfigSource{} or a
+//	        Vault-backed implementation.
+//	opts: Options controlling how the client is built, as for
+//	      CreateExternalClusterKubeRestClientContext.
+//
+// Returns:
+//
+//	A pointer to a configured kubernetes.Clientset ready for interacting with the cluster.
+//	An error if the configuration cannot be loaded, or as returned by
+//	CreateExternalClusterKubeRestClientContext.
+func CreateExternalClusterKubeRestClientFromSource(
+	ctx context.Context,
+	source ConfigSource,
+	opts ...Option,
+) (*kubernetes.Clientset, error) {
+	k8sconfig, err := source.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cluster configuration: %w", err)
+	}
+	return CreateExternalClusterKubeRestClientContext(ctx, k8sconfig, opts...)
+}
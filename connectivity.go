@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ConnectivityFailure classifies why a connectivity test failed.
+type ConnectivityFailure string
+
+// Connectivity failure classes reported by TestConnectivity.
+const (
+	// ConnectivityConfig means the configuration could not be turned into a rest.Config.
+	ConnectivityConfig ConnectivityFailure = "config"
+	// ConnectivityDNS means the API server host name could not be resolved.
+	ConnectivityDNS ConnectivityFailure = "dns"
+	// ConnectivityRefused means nothing accepted the TCP connection, or it could not be made.
+	ConnectivityRefused ConnectivityFailure = "connection"
+	// ConnectivityTimeout means the request did not complete before the deadline.
+	ConnectivityTimeout ConnectivityFailure = "timeout"
+	// ConnectivityTLS means the TLS handshake failed, e.g. an untrusted or mismatched certificate.
+	ConnectivityTLS ConnectivityFailure = "tls"
+	// ConnectivityUnauthorized means the API server rejected the credentials (HTTP 401).
+	ConnectivityUnauthorized ConnectivityFailure = "unauthorized"
+	// ConnectivityForbidden means the credentials may not read the health endpoint (HTTP 403).
+	ConnectivityForbidden ConnectivityFailure = "forbidden"
+	// ConnectivityUnhealthy means the API server answered but reported itself unhealthy.
+	ConnectivityUnhealthy ConnectivityFailure = "unhealthy"
+)
+
+// ConnectivityError is returned by TestConnectivity. Failure classifies the problem so
+// callers can react without parsing error strings; the underlying cause is wrapped.
+type ConnectivityError struct {
+	Cluster string
+	Failure ConnectivityFailure
+	Err     error
+}
+
+func (e *ConnectivityError) Error() string {
+	return fmt.Sprintf("connectivity test for cluster %s failed (%s): %v", e.Cluster, e.Failure, e.Err)
+}
+
+func (e *ConnectivityError) Unwrap() error {
+	return e.Err
+}
+
+// classifyTransportError maps an error returned by an HTTP client to a ConnectivityFailure.
+func classifyTransportError(err error) ConnectivityFailure {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return ConnectivityDNS
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr),
+		errors.As(err, &recordErr), strings.Contains(err.Error(), "tls:"):
+		return ConnectivityTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ConnectivityTimeout
+	default:
+		return ConnectivityRefused
+	}
+}
+
+// TestConnectivity checks that the cluster described by k8sconfig is reachable and
+// accepts its credentials, without building a typed clientset. It is intended for cheap
+// validation paths such as configuration linters.
+//
+// The rest.Config is built with BuildRestConfig and a single HTTPS GET is sent to the
+// API server's /livez endpoint, falling back to /healthz on servers that predate /livez.
+//
+// Parameters:
+//
+//	ctx: The context bounding the request.
+//	k8sconfig: A K8sConfig struct containing the connection details and credentials
+//	           for the target Kubernetes cluster.
+//
+// Returns:
+//
+//	nil if the API server reports itself healthy.
+//	A *ConnectivityError classifying the failure otherwise.
+func TestConnectivity(ctx context.Context, k8sconfig K8sConfig) error {
+	fail := func(failure ConnectivityFailure, err error) error {
+		return &ConnectivityError{Cluster: k8sconfig.Name, Failure: failure, Err: err}
+	}
+
+	restConfig, err := BuildRestConfig(k8sconfig)
+	if err != nil {
+		return fail(ConnectivityConfig, err)
+	}
+
+	httpClient, err := rest.HTTPClientFor(restConfig)
+	if err != nil {
+		return fail(ConnectivityConfig, err)
+	}
+
+	host := strings.TrimSuffix(restConfig.Host, "/")
+	for _, path := range []string{"/livez", "/healthz"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, host+path, http.NoBody)
+		if err != nil {
+			return fail(ConnectivityConfig, err)
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fail(classifyTransportError(err), err)
+		}
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if closeErr := resp.Body.Close(); readErr == nil {
+			readErr = closeErr
+		}
+		if readErr != nil {
+			return fail(ConnectivityRefused, readErr)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return nil
+		case resp.StatusCode == http.StatusNotFound && path == "/livez":
+			continue
+		case resp.StatusCode == http.StatusUnauthorized:
+			return fail(ConnectivityUnauthorized, fmt.Errorf("%s returned %s", path, resp.Status))
+		case resp.StatusCode == http.StatusForbidden:
+			return fail(ConnectivityForbidden, fmt.Errorf("%s returned %s", path, resp.Status))
+		default:
+			return fail(ConnectivityUnhealthy, fmt.Errorf("%s returned %s: %s", path, resp.Status, body))
+		}
+	}
+
+	return fail(ConnectivityUnhealthy, fmt.Errorf("no health endpoint found"))
+}
+
+// Ping checks that the API server behind clientset is reachable and healthy with a single
+// request to its /healthz endpoint, for wiring cluster reachability into a service's own
+// readiness or health checks. Unlike TestConnectivity it reuses an existing client, and
+// so its connection pool and credentials, instead of building one from a K8sConfig.
+//
+// A clientset whose discovery client has no REST client, such as the fake clientset from
+// k8s.io/client-go/kubernetes/fake, cannot send raw requests, so it is pinged by reading
+// the server version instead.
+//
+// Parameters:
+//
+//	ctx: The context bounding the request; its deadline is honored.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//
+// Returns:
+//
+//	nil if the API server reports itself healthy.
+//	An error if the request fails or the server reports itself unhealthy.
+func Ping(ctx context.Context, clientset kubernetes.Interface) error {
+	restClient := clientset.Discovery().RESTClient()
+	if restClient == nil {
+		if _, err := clientset.Discovery().ServerVersion(); err != nil {
+			return fmt.Errorf("API server health check failed: %w", err)
+		}
+		return nil
+	}
+
+	body, err := restClient.Get().AbsPath("/healthz").Do(ctx).Raw()
+	if err != nil {
+		return fmt.Errorf("API server health check failed: %w", err)
+	}
+	if status := strings.TrimSpace(string(body)); status != "ok" {
+		return fmt.Errorf("API server health check failed: /healthz returned %q", status)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestServerClientset starts an API server stub serving handler and returns a clientset
+// connected to it without a connection check.
+func newTestServerClientset(t *testing.T, handler http.Handler, opts ...Option) *kubernetes.Clientset {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	k8sconfig := K8sConfig{
+		Name:   "test",
+		Host:   server.URL,
+		Config: TLSClientConfig{Insecure: true, Token: "test-token"},
+	}
+	clientset, err := CreateExternalClusterKubeRestClient(k8sconfig, append(opts, WithSkipConnectionCheck())...)
+	if err != nil {
+		t.Fatalf("failed to create clientset: %v", err)
+	}
+	return clientset
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{name: "healthy", status: http.StatusOK, body: "ok"},
+		{name: "unhealthy body", status: http.StatusOK, body: "etcd failed", wantErr: true},
+		{name: "server error", status: http.StatusInternalServerError, body: "boom", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := newTestServerClientset(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/healthz" {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+
+			if err := Ping(context.Background(), clientset); (err != nil) != tt.wantErr {
+				t.Errorf("Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPingFakeClientset(t *testing.T) {
+	if err := Ping(context.Background(), fake.NewClientset()); err != nil {
+		t.Errorf("Ping() error = %v, want nil", err)
+	}
+
+	clientset := fake.NewClientset()
+	wantErr := errors.New("connection refused")
+	clientset.PrependReactor("get", "version", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, wantErr
+	})
+	if err := Ping(context.Background(), clientset); !errors.Is(err, wantErr) {
+		t.Errorf("Ping() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestConnectRetriesDefault(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		wantAttempts int32
+	}{
+		{name: "default", wantAttempts: 1},
+		{name: "one retry", opts: []Option{WithConnectRetries(1), WithConnectRetryDelay(time.Millisecond, 0)},
+			wantAttempts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			t.Cleanup(server.Close)
+
+			k8sconfig := K8sConfig{
+				Name:   "test",
+				Host:   server.URL,
+				Config: TLSClientConfig{Insecure: true, Token: "test-token"},
+			}
+			if _, err := CreateExternalClusterKubeRestClient(k8sconfig, tt.opts...); err == nil {
+				t.Fatal("CreateExternalClusterKubeRestClient() error = nil, want a connection error")
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("connection check made %d attempts, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
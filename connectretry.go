@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Defaults for retrying the connection check made by the constructors.
+const (
+	// DefaultConnectRetries is the number of retries made by the constructors without
+	// options. It is zero, so a bad configuration fails fast with a single attempt; use
+	// WithConnectRetries to ride out an API server that is still starting or scaling up.
+	DefaultConnectRetries = 0
+
+	// defaultConnectRetryBaseDelay is the delay before the first retry, doubled for each
+	// retry after it.
+	defaultConnectRetryBaseDelay = 500 * time.Millisecond
+
+	// defaultConnectRetryMaxDelay caps the delay between two retries.
+	defaultConnectRetryMaxDelay = 10 * time.Second
+)
+
+// verifyServerVersionWithRetry calls verifyServerVersion, retrying transient failures
+// (see isTransientError) with exponential backoff as configured by opts, until it
+// succeeds, fails permanently, runs out of retries, or ctx is done.
+func verifyServerVersionWithRetry(
+	ctx context.Context,
+	clientset *kubernetes.Clientset,
+	clusterName string,
+	opts clientOptions,
+) (*version.Info, error) {
+	delay := opts.connectRetryBaseDelay
+	if delay <= 0 {
+		delay = defaultConnectRetryBaseDelay
+	}
+	maxDelay := opts.connectRetryMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultConnectRetryMaxDelay
+	}
+
+	for attempt := 0; ; attempt++ {
+		serverVersion, err := verifyServerVersion(ctx, clientset, clusterName, opts.log())
+		if err == nil || attempt >= opts.connectRetries || ctx.Err() != nil || !isTransientError(err) {
+			return serverVersion, err
+		}
+
+		opts.log().Warn("Connection check failed, retrying", "cluster", clusterName,
+			"attempt", attempt+1, "retryIn", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxDelay)
+	}
+}
+
+// isTransientError reports whether err is likely to go away on its own, such as a
+// refused or reset connection, a timeout, or an API server that is overloaded or still
+// starting. Authentication, authorization, TLS, and other errors that retrying cannot
+// fix are not transient.
+func isTransientError(err error) bool {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.As(err, &dnsErr):
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	default:
+		return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+			apierrors.IsTooManyRequests(err) || apierrors.IsServiceUnavailable(err)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/version"
+	clientfeatures "k8s.io/client-go/features"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// Supported values for K8sConfig.ContentType.
+const (
+	// ContentTypeJSON requests and sends JSON. This is client-go's default.
+	ContentTypeJSON = runtime.ContentTypeJSON
+
+	// ContentTypeProtobuf requests and sends protobuf for built-in types, which is
+	// smaller and faster to decode than JSON. JSON is still accepted from the server
+	// for types that have no protobuf encoding (such as custom resources).
+	ContentTypeProtobuf = runtime.ContentTypeProtobuf
+
+	// ContentTypeCBOR requests and sends CBOR, a compact binary encoding that, unlike
+	// protobuf, also covers custom resources. JSON is accepted as a fallback. See
+	// applyContentType for the client and server requirements.
+	ContentTypeCBOR = runtime.ContentTypeCBOR
+)
+
+// minCBORServerVersion is the first Kubernetes release able to serve CBOR (behind the
+// CBORServingAndStorage feature gate). Older servers are never sent CBOR.
+var minCBORServerVersion = utilversion.MajorMinor(1, 32)
+
+// applyContentType configures the wire format used by restConfig.
+// An empty contentType leaves client-go's default (JSON) in place.
+//
+// CBOR support in client-go is itself behind the ClientsAllowCBOR client feature gate,
+// enabled by setting KUBE_FEATURE_ClientsAllowCBOR=true in the environment. Without it,
+// client-go would silently send JSON anyway, so JSON is configured and a warning logged.
+// With it, client-go falls back to JSON on its own if the server answers a CBOR request
+// with 415 Unsupported Media Type.
+//
+// Binary encodings are wrapped with a jsonFallbackRoundTripper, so reads whose response
+// cannot be decoded are retried once in JSON.
+func applyContentType(restConfig *rest.Config, contentType string) error {
+	switch contentType {
+	case "", ContentTypeJSON:
+		return nil
+	case ContentTypeProtobuf:
+		restConfig.ContentType = ContentTypeProtobuf
+		restConfig.AcceptContentTypes = ContentTypeProtobuf + "," + ContentTypeJSON
+		restConfig.Wrap(newJSONFallbackRoundTripper)
+		return nil
+	case ContentTypeCBOR:
+		if !clientfeatures.FeatureGates().Enabled(clientfeatures.ClientsAllowCBOR) {
+			Logger.Warn("CBOR requested but KUBE_FEATURE_ClientsAllowCBOR is not enabled, using JSON",
+				"host", restConfig.Host)
+			return nil
+		}
+		restConfig.ContentType = ContentTypeCBOR
+		restConfig.AcceptContentTypes = ContentTypeCBOR + "," + ContentTypeJSON
+		restConfig.Wrap(newJSONFallbackRoundTripper)
+		return nil
+	default:
+		return fmt.Errorf("unsupported content type %q", contentType)
+	}
+}
+
+// serverSupportsCBOR reports whether a server reporting serverVersion may be sent CBOR.
+func serverSupportsCBOR(serverVersion *version.Info) bool {
+	parsed, err := utilversion.ParseGeneric(serverVersion.GitVersion)
+	if err != nil {
+		return false
+	}
+
+	return parsed.AtLeast(minCBORServerVersion)
+}
+
+// downgradeCBOR switches restConfig back to JSON if it uses CBOR but the server is too old
+// to serve it. It reports whether the configuration was changed.
+func downgradeCBOR(restConfig *rest.Config, serverVersion *version.Info) bool {
+	if restConfig.ContentType != ContentTypeCBOR || serverSupportsCBOR(serverVersion) {
+		return false
+	}
+
+	Logger.Warn("Kubernetes version does not support CBOR, using JSON",
+		"version", serverVersion.GitVersion, "host", restConfig.Host)
+	restConfig.ContentType = ContentTypeJSON
+	restConfig.AcceptContentTypes = ContentTypeJSON
+	return true
+}
+
+// jsonFallbackRoundTripper retries a read in JSON, exactly once, when the server answers a
+// request for a binary encoding (protobuf or CBOR) with 406 Not Acceptable or with a body
+// that cannot be decoded.
+//
+// Protobuf schemas are generated per Kubernetes release, so during a cluster upgrade a
+// client and server can briefly disagree on a message layout and the client fails with an
+// opaque decode error. JSON decoding tolerates unknown and reordered fields, so retrying in
+// JSON usually succeeds. The retried response is decoded by client-go according to its
+// Content-Type, so callers never see the difference. Each fallback is logged so version
+// skew is visible.
+//
+// Only GET requests are retried, as they are safe to repeat and carry no encoded body.
+// Watch streams are passed through, since they cannot be checked without consuming them.
+// Checking a response means decoding it here as well as in client-go, which costs some of
+// the speed gained from the binary encoding in exchange for resilience.
+type jsonFallbackRoundTripper struct {
+	next   http.RoundTripper
+	codecs serializer.CodecFactory
+}
+
+// newJSONFallbackRoundTripper wraps next with a jsonFallbackRoundTripper decoding the
+// built-in Kubernetes types.
+func newJSONFallbackRoundTripper(next http.RoundTripper) http.RoundTripper {
+	return &jsonFallbackRoundTripper{
+		next:   next,
+		codecs: rest.CodecFactoryForGeneratedClient(scheme.Scheme, scheme.Codecs),
+	}
+}
+
+func (rt *jsonFallbackRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || isWatchRequest(req) || !isBinaryMediaType(req.Header.Get("Accept")) {
+		return rt.next.RoundTrip(req)
+	}
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	reason, err := rt.checkResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		return resp, nil
+	}
+
+	Logger.Warn("Failed to read binary response, retrying with JSON",
+		"accept", req.Header.Get("Accept"), "host", req.URL.Host, "reason", reason)
+	jsonReq := req.Clone(req.Context())
+	jsonReq.Header.Set("Accept", ContentTypeJSON)
+	return rt.next.RoundTrip(jsonReq)
+}
+
+// WrappedRoundTripper returns the round tripper this one delegates to.
+func (rt *jsonFallbackRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.next
+}
+
+// checkResponse returns why resp should be retried in JSON, or "" if it can be used. A
+// successful binary response is read into memory to decode it, and its body replaced with
+// the buffered data; a response to be retried is closed.
+func (rt *jsonFallbackRoundTripper) checkResponse(resp *http.Response) (string, error) {
+	if resp.StatusCode == http.StatusNotAcceptable {
+		closeBody(resp)
+		return "server cannot serve the requested encoding", nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || resp.StatusCode/100 != 2 || params["stream"] != "" || !isBinaryMediaType(mediaType) {
+		return "", nil
+	}
+	info, ok := runtime.SerializerInfoForMediaType(rt.codecs.SupportedMediaTypes(), mediaType)
+	if !ok {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	closeBody(resp)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Kinds outside the built-in scheme, such as aggregated APIs, are left to the caller
+	if _, _, err := info.Serializer.Decode(body, nil, nil); err != nil && !runtime.IsNotRegisteredError(err) {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+// isBinaryMediaType reports whether accept, an Accept header or a media type, prefers
+// protobuf or CBOR.
+func isBinaryMediaType(accept string) bool {
+	preferred, _, _ := strings.Cut(accept, ",")
+	mediaType, _, err := mime.ParseMediaType(preferred)
+	return err == nil && (mediaType == ContentTypeProtobuf || mediaType == ContentTypeCBOR)
+}
+
+// isWatchRequest reports whether req starts a watch stream.
+func isWatchRequest(req *http.Request) bool {
+	watch := req.URL.Query().Get("watch")
+	return watch == "true" || watch == "1"
+}
+
+// closeBody drains and closes the body of resp, so its connection can be reused. The
+// response is being discarded, so failures are only logged.
+func closeBody(resp *http.Response) {
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		Logger.Debug("Failed to drain response body", "error", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		Logger.Debug("Failed to close response body", "error", err)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	clientfeatures "k8s.io/client-go/features"
+	clientfeaturestesting "k8s.io/client-go/features/testing"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// cborAccept is the Accept header sent by a client configured with CBOR.
+const cborAccept = ContentTypeCBOR + "," + ContentTypeJSON
+
+// encodePodList encodes a list holding one pod named name in mediaType. CBOR is only
+// available while the ClientsAllowCBOR feature gate is enabled.
+func encodePodList(t *testing.T, mediaType, name string) []byte {
+	t.Helper()
+	codecs := rest.CodecFactoryForGeneratedClient(scheme.Scheme, scheme.Codecs)
+	info, ok := runtime.SerializerInfoForMediaType(codecs.SupportedMediaTypes(), mediaType)
+	if !ok {
+		t.Fatalf("no serializer for %s", mediaType)
+	}
+	podList := &corev1.PodList{Items: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: name}}}}
+	data, err := runtime.Encode(codecs.EncoderForVersion(info.Serializer, corev1.SchemeGroupVersion), podList)
+	if err != nil {
+		t.Fatalf("failed to encode pod list: %v", err)
+	}
+	return data
+}
+
+// newContentTypeClientset returns a clientset using contentType against handler.
+func newContentTypeClientset(
+	t *testing.T,
+	handler http.Handler,
+	contentType string,
+	opts ...Option,
+) *kubernetes.Clientset {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	k8sconfig := K8sConfig{
+		Name:        "test",
+		Host:        server.URL,
+		ContentType: contentType,
+		Config:      TLSClientConfig{Insecure: true, Token: "test-token"},
+	}
+	clientset, err := CreateExternalClusterKubeRestClient(k8sconfig, opts...)
+	if err != nil {
+		t.Fatalf("failed to create clientset: %v", err)
+	}
+	return clientset
+}
+
+// contentTypeServer serves pod lists, answering requests that prefer binaryType with
+// binaryStatus and binaryBody, and JSON requests with a list holding the pod "json".
+// It records the Accept header of every request but those for /version, which is
+// answered with gitVersion.
+type contentTypeServer struct {
+	t            *testing.T
+	binaryType   string
+	binaryStatus int
+	binaryBody   []byte
+	gitVersion   string
+
+	mu      sync.Mutex
+	accepts []string
+}
+
+func (s *contentTypeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/version" {
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		if err := json.NewEncoder(w).Encode(version.Info{GitVersion: s.gitVersion}); err != nil {
+			s.t.Errorf("failed to write version: %v", err)
+		}
+		return
+	}
+
+	accept := r.Header.Get("Accept")
+	s.mu.Lock()
+	s.accepts = append(s.accepts, accept)
+	s.mu.Unlock()
+
+	status, contentType, body := http.StatusOK, ContentTypeJSON, encodePodList(s.t, ContentTypeJSON, "json")
+	if strings.HasPrefix(accept, s.binaryType) {
+		status, contentType, body = s.binaryStatus, s.binaryType, s.binaryBody
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		s.t.Errorf("failed to write response: %v", err)
+	}
+}
+
+func TestProtobufJSONFallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		binaryStatus int
+		binaryBody   func(t *testing.T) []byte
+		wantPod      string
+		wantRequests int
+	}{
+		{
+			name:         "decodable",
+			binaryStatus: http.StatusOK,
+			binaryBody:   func(t *testing.T) []byte { return encodePodList(t, ContentTypeProtobuf, "protobuf") },
+			wantPod:      "protobuf",
+			wantRequests: 1,
+		},
+		{
+			name:         "undecodable",
+			binaryStatus: http.StatusOK,
+			binaryBody:   func(*testing.T) []byte { return []byte("k8s\x00\x0a\xff\xff\xff") },
+			wantPod:      "json",
+			wantRequests: 2,
+		},
+		{
+			name:         "not acceptable",
+			binaryStatus: http.StatusNotAcceptable,
+			binaryBody:   func(*testing.T) []byte { return nil },
+			wantPod:      "json",
+			wantRequests: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &contentTypeServer{
+				t:            t,
+				binaryType:   ContentTypeProtobuf,
+				binaryStatus: tt.binaryStatus,
+				binaryBody:   tt.binaryBody(t),
+			}
+			clientset := newContentTypeClientset(t, server, ContentTypeProtobuf, WithSkipConnectionCheck())
+
+			pods, err := clientset.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(pods.Items) != 1 || pods.Items[0].Name != tt.wantPod {
+				t.Errorf("List() = %v, want the pod %q", pods.Items, tt.wantPod)
+			}
+			if len(server.accepts) != tt.wantRequests {
+				t.Errorf("server received Accept headers %q, want %d requests", server.accepts, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestCBORJSONFallback(t *testing.T) {
+	clientfeaturestesting.SetFeatureDuringTest(t, clientfeatures.ClientsAllowCBOR, true)
+
+	tests := []struct {
+		name         string
+		gitVersion   string
+		binaryStatus int
+		binaryBody   func(t *testing.T) []byte
+		wantPod      string
+		wantAccepts  []string
+	}{
+		{
+			name:         "decodable",
+			gitVersion:   "v1.32.0",
+			binaryStatus: http.StatusOK,
+			binaryBody:   func(t *testing.T) []byte { return encodePodList(t, ContentTypeCBOR, "cbor") },
+			wantPod:      "cbor",
+			wantAccepts:  []string{cborAccept},
+		},
+		{
+			name:         "not acceptable",
+			gitVersion:   "v1.33.1",
+			binaryStatus: http.StatusNotAcceptable,
+			binaryBody:   func(*testing.T) []byte { return nil },
+			wantPod:      "json",
+			wantAccepts:  []string{cborAccept, ContentTypeJSON},
+		},
+		{
+			name:         "undecodable",
+			gitVersion:   "v1.33.1",
+			binaryStatus: http.StatusOK,
+			binaryBody:   func(*testing.T) []byte { return []byte{0xd9, 0xd9, 0xf7, 0xff} },
+			wantPod:      "json",
+			wantAccepts:  []string{cborAccept, ContentTypeJSON},
+		},
+		{
+			name:         "server too old",
+			gitVersion:   "v1.31.4",
+			binaryStatus: http.StatusOK,
+			binaryBody:   func(t *testing.T) []byte { return encodePodList(t, ContentTypeCBOR, "cbor") },
+			wantPod:      "json",
+			wantAccepts:  []string{ContentTypeJSON},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &contentTypeServer{
+				t:            t,
+				binaryType:   ContentTypeCBOR,
+				binaryStatus: tt.binaryStatus,
+				binaryBody:   tt.binaryBody(t),
+				gitVersion:   tt.gitVersion,
+			}
+			clientset := newContentTypeClientset(t, server, ContentTypeCBOR, WithConnectRetries(0))
+
+			pods, err := clientset.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(pods.Items) != 1 || pods.Items[0].Name != tt.wantPod {
+				t.Errorf("List() = %v, want the pod %q", pods.Items, tt.wantPod)
+			}
+			if !slices.Equal(server.accepts, tt.wantAccepts) {
+				t.Errorf("server received Accept headers %q, want %q", server.accepts, tt.wantAccepts)
+			}
+		})
+	}
+}
+
+func TestValidateContentType(t *testing.T) {
+	for _, contentType := range []string{"", ContentTypeJSON, ContentTypeProtobuf, ContentTypeCBOR} {
+		k8sconfig := K8sConfig{Name: "test", Host: "https://127.0.0.1", ContentType: contentType,
+			Config: TLSClientConfig{Insecure: true}}
+		if err := k8sconfig.Validate(); err != nil {
+			t.Errorf("Validate() with content type %q error = %v", contentType, err)
+		}
+	}
+
+	k8sconfig := K8sConfig{Name: "test", Host: "https://127.0.0.1", ContentType: "application/yaml",
+		Config: TLSClientConfig{Insecure: true}}
+	if err := k8sconfig.Validate(); err == nil || !strings.Contains(err.Error(), "unsupported content type") {
+		t.Errorf("Validate() with content type application/yaml error = %v, want unsupported content type", err)
+	}
+}
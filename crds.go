@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// crdPollInterval is how often WaitForCRDAndWatch checks for the CRD while it is absent.
+const crdPollInterval = 2 * time.Second
+
+// isCRDEstablished reports whether the API server is serving the CRD's resources.
+func isCRDEstablished(crd *apiextensionsv1.CustomResourceDefinition) bool {
+	for _, condition := range crd.Status.Conditions {
+		if condition.Type == apiextensionsv1.Established {
+			return condition.Status == apiextensionsv1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// crdServedVersion returns preferred if the CRD serves it, otherwise the CRD's storage
+// version (or its first served version), so watches survive a version being retired.
+func crdServedVersion(crd *apiextensionsv1.CustomResourceDefinition, preferred string) string {
+	fallback := ""
+	for _, version := range crd.Spec.Versions {
+		if !version.Served {
+			continue
+		}
+		if version.Name == preferred {
+			return preferred
+		}
+		if version.Storage || fallback == "" {
+			fallback = version.Name
+		}
+	}
+
+	return fallback
+}
+
+// waitForEstablishedCRD polls until the named CRD exists, is established, and serves at least one version.
+func waitForEstablishedCRD(
+	ctx context.Context,
+	apiextClient apiextensionsclientset.Interface,
+	crdName string,
+) (*apiextensionsv1.CustomResourceDefinition, error) {
+	var crd *apiextensionsv1.CustomResourceDefinition
+	err := wait.PollUntilContextCancel(ctx, crdPollInterval, true, func(ctx context.Context) (bool, error) {
+		crds := apiextClient.ApiextensionsV1().CustomResourceDefinitions()
+		current, err := crds.Get(ctx, crdName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to get CRD %s: %w", crdName, err)
+		}
+
+		crd = current
+		return isCRDEstablished(crd) && crdServedVersion(crd, "") != "", nil
+	})
+
+	return crd, err
+}
+
+// watchUntilCRDChanges watches resource and passes its events to handler until the CRD is
+// deleted, recreated, stops being established, or stops serving the watched version. It
+// returns nil in those cases, and when ctx is done, so the caller can wait for the CRD again.
+func watchUntilCRDChanges(
+	ctx context.Context,
+	apiextClient apiextensionsclientset.Interface,
+	dynClient dynamic.Interface,
+	crd *apiextensionsv1.CustomResourceDefinition,
+	resource schema.GroupVersionResource,
+	handler func(watch.Event),
+) error {
+	crdWatch, err := apiextClient.ApiextensionsV1().CustomResourceDefinitions().Watch(ctx, metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("metadata.name", crd.Name).String(),
+		ResourceVersion: crd.ResourceVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch CRD %s: %w", crd.Name, err)
+	}
+	defer crdWatch.Stop()
+
+	resourceVersion := ""
+	for {
+		resourceWatch, err := dynClient.Resource(resource).Watch(ctx, metav1.ListOptions{
+			ResourceVersion:     resourceVersion,
+			AllowWatchBookmarks: true,
+		})
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case apierrors.IsNotFound(err):
+			// The CRD is being removed or gvr names a resource it does not define; back
+			// off before the caller checks the CRD again to avoid a hot loop
+			select {
+			case <-ctx.Done():
+			case <-time.After(crdPollInterval):
+			}
+			return nil
+		case apierrors.IsResourceExpired(err), apierrors.IsGone(err):
+			resourceVersion = ""
+			continue
+		case err != nil:
+			return fmt.Errorf("failed to watch %s: %w", resource.String(), err)
+		}
+
+		changed, expired := forwardCRDEvents(ctx, crdWatch, resourceWatch, crd, resource, handler, &resourceVersion)
+		resourceWatch.Stop()
+		if changed {
+			return nil
+		}
+		if expired {
+			resourceVersion = ""
+		}
+	}
+}
+
+// forwardCRDEvents passes events from resourceWatch to handler, tracking the last seen
+// resourceVersion, until either watch ends. It reports whether the CRD changed (or ctx is
+// done) and whether the resource watch ended because its resourceVersion expired.
+func forwardCRDEvents(
+	ctx context.Context,
+	crdWatch, resourceWatch watch.Interface,
+	crd *apiextensionsv1.CustomResourceDefinition,
+	resource schema.GroupVersionResource,
+	handler func(watch.Event),
+	resourceVersion *string,
+) (changed, expired bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return true, false
+
+		case event, ok := <-crdWatch.ResultChan():
+			if !ok {
+				return true, false
+			}
+			current, isCRD := event.Object.(*apiextensionsv1.CustomResourceDefinition)
+			switch event.Type {
+			case watch.Deleted, watch.Error:
+				return true, false
+			case watch.Added, watch.Modified:
+				if !isCRD || current.UID != crd.UID || !isCRDEstablished(current) ||
+					crdServedVersion(current, resource.Version) != resource.Version {
+					return true, false
+				}
+			case watch.Bookmark:
+			}
+
+		case event, ok := <-resourceWatch.ResultChan():
+			if !ok {
+				return false, false
+			}
+			if event.Type == watch.Error {
+				return false, apierrors.IsResourceExpired(apierrors.FromObject(event.Object)) ||
+					apierrors.IsGone(apierrors.FromObject(event.Object))
+			}
+			if accessor, err := meta.Accessor(event.Object); err == nil {
+				*resourceVersion = accessor.GetResourceVersion()
+			}
+			if event.Type != watch.Bookmark {
+				handler(event)
+			}
+		}
+	}
+}
+
+// WaitForCRDAndWatch waits for a CustomResourceDefinition to be installed and established,
+// then watches its custom resources across all namespaces, passing every event to handler,
+// until ctx is cancelled. It is intended for operators that must start reconciling a
+// custom resource only once its CRD appears at runtime.
+//
+// The CRD is watched alongside its resources. If the CRD is deleted, recreated, or stops
+// being established, the resource watch is stopped and WaitForCRDAndWatch goes back to
+// waiting for it. If the CRD stops serving gvr.Version, the watch is re-established on the
+// CRD's storage version (or another served version) instead, so the Version in events
+// passed to handler may differ from gvr.Version. Watches closed by the server are resumed
+// from the last seen resourceVersion, or restarted from scratch if it has expired.
+//
+// As with informers, handler may see the same object more than once across restarts
+// (every existing object is re-delivered as an Added event), so it should be idempotent.
+// Bookmark events are consumed internally and not passed to handler.
+//
+// Parameters:
+//
+//	ctx: The context controlling how long to wait and watch.
+//	apiextClient: The apiextensions client used to read and watch the CRD.
+//	dynClient: The dynamic client used to watch the custom resources.
+//	crdName: The name of the CRD, e.g. "widgets.example.com".
+//	gvr: The group/version/resource of the custom resources to watch.
+//	handler: The function called for every resource event.
+//
+// Returns:
+//
+//	nil when ctx is cancelled.
+//	An error if reading or watching the CRD or its resources fails.
+func WaitForCRDAndWatch(
+	ctx context.Context,
+	apiextClient apiextensionsclientset.Interface,
+	dynClient dynamic.Interface,
+	crdName string,
+	gvr schema.GroupVersionResource,
+	handler func(watch.Event),
+) error {
+	for {
+		crd, err := waitForEstablishedCRD(ctx, apiextClient, crdName)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		resource := gvr
+		resource.Version = crdServedVersion(crd, gvr.Version)
+		if resource.Version != gvr.Version {
+			Logger.Warn("CRD does not serve the requested version, watching another version instead",
+				"crd", crdName, "requestedVersion", gvr.Version, "version", resource.Version)
+		}
+
+		if err := watchUntilCRDChanges(ctx, apiextClient, dynClient, crd, resource, handler); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s.io/client-go/rest"
+)
+
+// DescribeRestConfig renders the effective settings of restConfig as a multi-line string
+// that is safe to log: secrets are never included, only whether they are set. It is meant
+// for debugging connection problems where the settings were assembled from several
+// sources (environment, files, defaults) and it is unclear which ones won.
+//
+// Unset QPS, Burst, and user agent values are shown as the defaults client-go applies.
+//
+// Parameters:
+//
+//	restConfig: The REST configuration to describe.
+//
+// Returns:
+//
+//	One "name: value" line per setting.
+func DescribeRestConfig(restConfig *rest.Config) string {
+	var b strings.Builder
+	line := func(name string, value any) {
+		fmt.Fprintf(&b, "%s: %v\n", name, value)
+	}
+
+	line("host", restConfig.Host)
+	if restConfig.APIPath != "" {
+		line("apiPath", restConfig.APIPath)
+	}
+
+	// Authentication, redacted
+	line("bearerToken", redacted(restConfig.BearerToken != ""))
+	if restConfig.BearerTokenFile != "" {
+		line("bearerTokenFile", restConfig.BearerTokenFile)
+	}
+	if restConfig.Username != "" {
+		line("basicAuth", "username "+restConfig.Username+", password "+redacted(restConfig.Password != ""))
+	}
+	line("clientCert", describeCredential(len(restConfig.CertData) > 0, restConfig.CertFile))
+	line("clientKey", describeCredential(len(restConfig.KeyData) > 0, restConfig.KeyFile))
+	if restConfig.ExecProvider != nil {
+		line("execProvider", restConfig.ExecProvider.Command)
+	}
+	if restConfig.AuthProvider != nil {
+		line("authProvider", restConfig.AuthProvider.Name)
+	}
+	if restConfig.Impersonate.UserName != "" {
+		line("impersonate", restConfig.Impersonate.UserName)
+	}
+
+	// TLS
+	line("insecure", restConfig.Insecure)
+	line("ca", describeCredential(len(restConfig.CAData) > 0, restConfig.CAFile))
+	if restConfig.ServerName != "" {
+		line("serverName", restConfig.ServerName)
+	}
+
+	// Client behaviour
+	qps, burst := restConfig.QPS, restConfig.Burst
+	if qps == 0 {
+		qps = rest.DefaultQPS
+	}
+	if burst == 0 {
+		burst = rest.DefaultBurst
+	}
+	line("qps", qps)
+	line("burst", burst)
+	if restConfig.RateLimiter != nil {
+		line("rateLimiter", "custom (overrides qps and burst)")
+	}
+	timeout := "none"
+	if restConfig.Timeout > 0 {
+		timeout = restConfig.Timeout.String()
+	}
+	line("timeout", timeout)
+	line("proxy", describeProxy(restConfig))
+	userAgent := restConfig.UserAgent
+	if userAgent == "" {
+		userAgent = rest.DefaultKubernetesUserAgent()
+	}
+	line("userAgent", userAgent)
+	if restConfig.ContentType != "" {
+		line("contentType", restConfig.ContentType)
+	}
+	if restConfig.Transport != nil {
+		line("transport", fmt.Sprintf("custom %T (TLS settings above are ignored)", restConfig.Transport))
+	}
+	if restConfig.WrapTransport != nil {
+		line("wrapTransport", "set")
+	}
+
+	return b.String()
+}
+
+// redacted describes whether a secret is set without revealing it.
+func redacted(set bool) string {
+	if set {
+		return "[redacted]"
+	}
+	return "not set"
+}
+
+// describeCredential describes a credential that may be given inline or as a file path.
+// Inline data wins over a file, as in client-go.
+func describeCredential(hasData bool, file string) string {
+	switch {
+	case hasData:
+		return "inline data [redacted]"
+	case file != "":
+		return "file " + file
+	default:
+		return "not set"
+	}
+}
+
+// describeProxy reports the proxy requests to the API server are sent through.
+func describeProxy(restConfig *rest.Config) string {
+	if restConfig.Proxy == nil {
+		return "from environment (HTTPS_PROXY, HTTP_PROXY, NO_PROXY)"
+	}
+
+	req, err := http.NewRequest(http.MethodGet, restConfig.Host, nil)
+	if err != nil {
+		return "custom"
+	}
+	proxyURL, err := restConfig.Proxy(req)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("custom (error: %v)", err)
+	case proxyURL == nil:
+		return "none"
+	default:
+		return proxyURL.Redacted()
+	}
+}
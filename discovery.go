@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// discoveryRoundTripper binds discovery requests to ctx, which the discovery client has no
+// parameter for, and records whether the API server answered /apis with an aggregated
+// discovery document (APIGroupDiscoveryList).
+type discoveryRoundTripper struct {
+	next       http.RoundTripper
+	ctx        context.Context
+	aggregated atomic.Bool
+}
+
+func (rt *discoveryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.next.RoundTrip(req.WithContext(rt.ctx))
+	if err == nil && req.URL.Path == "/apis" &&
+		strings.Contains(resp.Header.Get("Content-Type"), "as=APIGroupDiscoveryList") {
+		rt.aggregated.Store(true)
+	}
+	return resp, err
+}
+
+// WrappedRoundTripper returns the round tripper this one delegates to.
+func (rt *discoveryRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.next
+}
+
+// NewRESTMapper builds a RESTMapper from the API server's discovery information.
+//
+// When preferAggregated is true, the discovery client asks for aggregated discovery (the
+// apidiscovery.k8s.io APIGroupDiscoveryList format), which returns every group and resource
+// in a single round-trip instead of one request per group version. A cluster that does not
+// support it answers the same request in the legacy format, and client-go continues with
+// legacy discovery, so no separate probe is needed. If aggregated discovery fails outright,
+// e.g. because a proxy rejects its Accept header, discovery is retried in the legacy format.
+// The path that was used is logged so slow startups can be diagnosed.
+//
+// When preferAggregated is false, legacy discovery is always used.
+//
+// Parameters:
+//
+//	ctx: The context bounding every discovery request.
+//	restConfig: The REST configuration used to reach the API server.
+//	preferAggregated: Whether to prefer aggregated discovery when the server supports it.
+//
+// Returns:
+//
+//	A meta.RESTMapper able to map kinds to resources for every API served by the cluster.
+//	An error if the discovery client cannot be created or discovery fails.
+func NewRESTMapper(ctx context.Context, restConfig *rest.Config, preferAggregated bool) (meta.RESTMapper, error) {
+	groupResources, aggregated, err := discoverAPIGroupResources(ctx, restConfig, !preferAggregated)
+	if err != nil && preferAggregated && ctx.Err() == nil {
+		Logger.Warn("Aggregated discovery failed, retrying with legacy discovery",
+			"host", restConfig.Host, "error", err)
+		groupResources, aggregated, err = discoverAPIGroupResources(ctx, restConfig, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if aggregated {
+		Logger.Info("Used aggregated discovery to build REST mapper", "host", restConfig.Host)
+	} else {
+		Logger.Info("Used legacy discovery to build REST mapper", "host", restConfig.Host)
+	}
+
+	return restmapper.NewDiscoveryRESTMapper(groupResources), nil
+}
+
+// discoverAPIGroupResources fetches the API group resources served by the cluster, bounded
+// by ctx, and reports whether the server answered with aggregated discovery.
+func discoverAPIGroupResources(
+	ctx context.Context,
+	restConfig *rest.Config,
+	useLegacy bool,
+) ([]*restmapper.APIGroupResources, bool, error) {
+	recorder := &discoveryRoundTripper{ctx: ctx}
+	config := rest.CopyConfig(restConfig)
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		recorder.next = rt
+		return recorder
+	})
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	discoveryClient.UseLegacyDiscovery = useLegacy
+
+	groupResources, err := restmapper.GetAPIGroupResources(discoveryClient)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to discover API group resources: %w", err)
+	}
+	return groupResources, recorder.aggregated.Load(), nil
+}
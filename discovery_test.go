@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	apidiscoveryv2 "k8s.io/api/apidiscovery/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// discoveryServer serves the discovery endpoints of a cluster with the core v1 and apps/v1
+// groups, in the aggregated format if aggregated is set and the client asks for it, or
+// rejecting requests for it if rejectAggregated is set. It counts the requests for /apis
+// and for the legacy apps/v1 resource list.
+type discoveryServer struct {
+	aggregated       bool
+	rejectAggregated bool
+	apisRequests     atomic.Int32
+	appsRequests     atomic.Int32
+}
+
+func (s *discoveryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wantsAggregated := strings.Contains(r.Header.Get("Accept"), "apidiscovery.k8s.io")
+	var body any
+	contentType := "application/json"
+
+	switch r.URL.Path {
+	case "/api":
+		if wantsAggregated && s.aggregated {
+			contentType = discovery.AcceptV2
+			body = aggregatedDiscovery("", "Pod", "pods")
+			break
+		}
+		body = metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}}
+	case "/api/v1":
+		body = metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get", "list"}},
+		}}
+	case "/apis":
+		s.apisRequests.Add(1)
+		switch {
+		case wantsAggregated && s.rejectAggregated:
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		case wantsAggregated && s.aggregated:
+			contentType = discovery.AcceptV2
+			body = aggregatedDiscovery("apps", "Deployment", "deployments")
+		default:
+			appsV1 := metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"}
+			body = metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList"}, Groups: []metav1.APIGroup{
+				{Name: "apps", Versions: []metav1.GroupVersionForDiscovery{appsV1}, PreferredVersion: appsV1},
+			}}
+		}
+	case "/apis/apps/v1":
+		s.appsRequests.Add(1)
+		body = metav1.APIResourceList{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Namespaced: true, Kind: "Deployment", Verbs: metav1.Verbs{"get", "list"}},
+		}}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// aggregatedDiscovery returns the aggregated discovery document of the v1 version of group,
+// serving a single namespaced resource of kind.
+func aggregatedDiscovery(group, kind, resource string) apidiscoveryv2.APIGroupDiscoveryList {
+	discovered := apidiscoveryv2.APIResourceDiscovery{
+		Resource:     resource,
+		ResponseKind: &metav1.GroupVersionKind{Group: group, Version: "v1", Kind: kind},
+		Scope:        apidiscoveryv2.ScopeNamespace,
+		Verbs:        []string{"get", "list"},
+	}
+	return apidiscoveryv2.APIGroupDiscoveryList{
+		TypeMeta: metav1.TypeMeta{Kind: "APIGroupDiscoveryList", APIVersion: "apidiscovery.k8s.io/v2"},
+		Items: []apidiscoveryv2.APIGroupDiscovery{{
+			ObjectMeta: metav1.ObjectMeta{Name: group},
+			Versions: []apidiscoveryv2.APIVersionDiscovery{{
+				Version:   "v1",
+				Resources: []apidiscoveryv2.APIResourceDiscovery{discovered},
+				Freshness: apidiscoveryv2.DiscoveryFreshnessCurrent,
+			}},
+		}},
+	}
+}
+
+func TestNewRESTMapper(t *testing.T) {
+	tests := []struct {
+		name             string
+		server           *discoveryServer
+		preferAggregated bool
+		wantAPIs         int32
+		wantApps         int32
+	}{
+		{name: "aggregated", server: &discoveryServer{aggregated: true}, preferAggregated: true, wantAPIs: 1},
+		{name: "legacy server", server: &discoveryServer{}, preferAggregated: true, wantAPIs: 1, wantApps: 1},
+		{name: "aggregated rejected", server: &discoveryServer{rejectAggregated: true}, preferAggregated: true,
+			wantAPIs: 2, wantApps: 1},
+		{name: "legacy preferred", server: &discoveryServer{aggregated: true}, wantAPIs: 1, wantApps: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.server)
+			t.Cleanup(server.Close)
+
+			mapper, err := NewRESTMapper(context.Background(), &rest.Config{Host: server.URL}, tt.preferAggregated)
+			if err != nil {
+				t.Fatalf("NewRESTMapper() error = %v", err)
+			}
+			if _, err := mapper.RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "v1"); err != nil {
+				t.Errorf("RESTMapping(apps/Deployment) error = %v", err)
+			}
+
+			if got := tt.server.apisRequests.Load(); got != tt.wantAPIs {
+				t.Errorf("/apis requested %d times, want %d", got, tt.wantAPIs)
+			}
+			if got := tt.server.appsRequests.Load(); got != tt.wantApps {
+				t.Errorf("/apis/apps/v1 requested %d times, want %d", got, tt.wantApps)
+			}
+		})
+	}
+}
+
+func TestNewRESTMapperContext(t *testing.T) {
+	server := httptest.NewServer(&discoveryServer{aggregated: true})
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewRESTMapper(ctx, &rest.Config{Host: server.URL}, true); err == nil {
+		t.Error("NewRESTMapper() error = nil, want an error for a cancelled context")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// podNames is application code written against kubernetes.Interface, so it accepts both
+// the clientset returned by the constructors and the fake clientset used below.
+func podNames(ctx context.Context, clientset kubernetes.Interface) ([]string, error) {
+	pods, err := ListAllPods(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Example_fakeClientset shows how to unit test code built on this package: seed the fake
+// clientset from k8s.io/client-go/kubernetes/fake with the objects the test needs and pass
+// it wherever a kubernetes.Interface is accepted.
+func Example_fakeClientset() {
+	clientset := fake.NewClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "jobs"}},
+	)
+
+	if err := Ping(context.Background(), clientset); err != nil {
+		fmt.Println("ping failed:", err)
+		return
+	}
+
+	names, err := podNames(context.Background(), clientset)
+	if err != nil {
+		fmt.Println("list failed:", err)
+		return
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+
+	info, err := GetClusterInfo(context.Background(), clientset)
+	if err != nil {
+		fmt.Println("cluster info failed:", err)
+		return
+	}
+	fmt.Println("nodes:", info.NodeCount)
+
+	// Output:
+	// default/web-0
+	// jobs/worker-0
+	// nodes: 0
+}
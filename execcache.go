@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// execCredential is the subset of the client.authentication.k8s.io ExecCredential object
+// that is exchanged with exec credential plugins and persisted in the cache.
+type execCredential struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Spec       execCredentialSpec   `json:"spec"`
+	Status     execCredentialStatus `json:"status,omitzero"`
+}
+
+type execCredentialSpec struct {
+	Interactive bool `json:"interactive"`
+}
+
+type execCredentialStatus struct {
+	Token               string     `json:"token"`
+	ExpirationTimestamp *time.Time `json:"expirationTimestamp,omitempty"`
+}
+
+// ExecCredentialCache caches bearer tokens minted by exec credential plugins (such as
+// aws-iam-authenticator or gke-gcloud-auth-plugin) on disk, so repeated process starts do
+// not pay the cost of running the plugin every time.
+//
+// Entries are keyed by a SHA-256 hash of the plugin's apiVersion, command, arguments,
+// and environment, so different plugins or identities never share a token. Cache files
+// are written with 0600 permissions inside a directory created with 0700 permissions.
+// Only token credentials are supported; plugins that return client certificates must use
+// client-go's built-in exec support instead.
+//
+// Tokens are also kept in memory until they go stale, so the file is only read, and the
+// plugin only run, when a token is first needed or must be refreshed.
+type ExecCredentialCache struct {
+	// Dir is the directory the cache files are stored in.
+	Dir string
+
+	// RefreshBefore is how long before its expiry a cached token is considered stale and
+	// the plugin is run again. Defaults to one minute when zero.
+	RefreshBefore time.Duration
+
+	// TTL bounds how long a token is reused. It applies to tokens returned without an
+	// expirationTimestamp and caps the lifetime of those that have one. When zero,
+	// tokens without an expiry are not cached.
+	TTL time.Duration
+
+	// mu guards tokens, the in-memory credentials by cache file path. refreshMu serializes
+	// cache misses, so concurrent requests run the plugin once, without blocking hits.
+	mu        sync.Mutex
+	tokens    map[string]*execCredential
+	refreshMu sync.Mutex
+}
+
+// Token returns a valid bearer token for the given exec plugin, from memory or the cache
+// file when a fresh entry exists, otherwise running the plugin and caching its result.
+//
+// Parameters:
+//
+//	ctx: The context bounding the plugin invocation.
+//	execConfig: The exec plugin configuration, in kubeconfig form.
+//
+// Returns:
+//
+//	The bearer token.
+//	An error if the plugin fails or returns no token, or if the cache cannot be written.
+func (c *ExecCredentialCache) Token(ctx context.Context, execConfig *clientcmdapi.ExecConfig) (string, error) {
+	path := c.path(execConfig)
+	if cred := c.cached(path); cred != nil {
+		return cred.Status.Token, nil
+	}
+
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	// Another request may have refreshed the token while this one waited
+	if cred := c.cached(path); cred != nil {
+		return cred.Status.Token, nil
+	}
+	if cred, err := c.read(path); err == nil && c.fresh(cred) {
+		c.store(path, cred)
+		return cred.Status.Token, nil
+	}
+
+	cred, err := runExecPlugin(ctx, execConfig)
+	if err != nil {
+		return "", err
+	}
+
+	if c.TTL > 0 {
+		limit := time.Now().Add(c.TTL)
+		if cred.Status.ExpirationTimestamp == nil || cred.Status.ExpirationTimestamp.After(limit) {
+			cred.Status.ExpirationTimestamp = &limit
+		}
+	}
+	if cred.Status.ExpirationTimestamp != nil {
+		if err := c.write(path, cred); err != nil {
+			return "", err
+		}
+		c.store(path, cred)
+	}
+
+	return cred.Status.Token, nil
+}
+
+// Invalidate removes the cached token for the given exec plugin, forcing the next call
+// to Token to run the plugin.
+func (c *ExecCredentialCache) Invalidate(execConfig *clientcmdapi.ExecConfig) error {
+	path := c.path(execConfig)
+	c.mu.Lock()
+	delete(c.tokens, path)
+	c.mu.Unlock()
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove cached exec credential: %w", err)
+	}
+
+	return nil
+}
+
+// Apply configures restConfig to authenticate every request with a bearer token obtained
+// from the cache for the given exec plugin. The token is looked up per request, so a
+// token nearing expiry is transparently replaced, and a 401 response invalidates the
+// cached entry so the following request runs the plugin again.
+func (c *ExecCredentialCache) Apply(restConfig *rest.Config, execConfig *clientcmdapi.ExecConfig) {
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &execCacheRoundTripper{cache: c, execConfig: execConfig, next: rt}
+	})
+}
+
+// path returns the cache file path for the given exec plugin.
+func (c *ExecCredentialCache) path(execConfig *clientcmdapi.ExecConfig) string {
+	parts := []string{execConfig.APIVersion, execConfig.Command}
+	parts = append(parts, execConfig.Args...)
+
+	env := make([]string, 0, len(execConfig.Env))
+	for _, e := range execConfig.Env {
+		env = append(env, e.Name+"="+e.Value)
+	}
+	sort.Strings(env)
+	parts = append(parts, env...)
+
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+
+	return filepath.Join(c.Dir, hex.EncodeToString(hash[:])+".json")
+}
+
+// cached returns the in-memory credential for path if it is still fresh, otherwise nil.
+func (c *ExecCredentialCache) cached(path string) *execCredential {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cred := c.tokens[path]; cred != nil && c.fresh(cred) {
+		return cred
+	}
+	return nil
+}
+
+// store keeps cred in memory as the credential for path.
+func (c *ExecCredentialCache) store(path string, cred *execCredential) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tokens == nil {
+		c.tokens = map[string]*execCredential{}
+	}
+	c.tokens[path] = cred
+}
+
+// fresh reports whether a cached credential can still be used.
+func (c *ExecCredentialCache) fresh(cred *execCredential) bool {
+	if cred.Status.Token == "" || cred.Status.ExpirationTimestamp == nil {
+		return false
+	}
+
+	refreshBefore := c.RefreshBefore
+	if refreshBefore == 0 {
+		refreshBefore = time.Minute
+	}
+
+	return time.Now().Add(refreshBefore).Before(*cred.Status.ExpirationTimestamp)
+}
+
+// read loads a cached credential from path.
+func (c *ExecCredentialCache) read(path string) (*execCredential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cred execCredential
+	if err := json.Unmarshal(data, &cred); err != nil {
+		return nil, err
+	}
+
+	return &cred, nil
+}
+
+// write atomically stores cred at path, readable only by the current user.
+func (c *ExecCredentialCache) write(path string, cred *execCredential) error {
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create exec credential cache directory %s: %w", c.Dir, err)
+	}
+
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return fmt.Errorf("failed to encode exec credential: %w", err)
+	}
+
+	// CreateTemp creates the file with 0600 permissions
+	tmp, err := os.CreateTemp(c.Dir, ".exec-credential-*")
+	if err != nil {
+		return fmt.Errorf("failed to create exec credential cache file: %w", err)
+	}
+
+	_, writeErr := tmp.Write(data)
+	if err := errors.Join(writeErr, tmp.Close()); err != nil {
+		return errors.Join(fmt.Errorf("failed to write exec credential cache file: %w", err), os.Remove(tmp.Name()))
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Join(fmt.Errorf("failed to store exec credential cache file: %w", err), os.Remove(tmp.Name()))
+	}
+
+	return nil
+}
+
+// runExecPlugin runs the exec plugin non-interactively and parses the ExecCredential it prints.
+func runExecPlugin(ctx context.Context, execConfig *clientcmdapi.ExecConfig) (*execCredential, error) {
+	// Plugins configured without an API version are sent the stable one
+	apiVersion := execConfig.APIVersion
+	if apiVersion == "" {
+		apiVersion = ExecAPIVersionV1
+	}
+	execInfo, err := json.Marshal(execCredential{
+		APIVersion: apiVersion,
+		Kind:       "ExecCredential",
+		Spec:       execCredentialSpec{Interactive: false},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode exec credential request: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, execConfig.Command, execConfig.Args...)
+	cmd.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(execInfo))
+	for _, e := range execConfig.Env {
+		cmd.Env = append(cmd.Env, e.Name+"="+e.Value)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("exec plugin %s failed: %w: %s", execConfig.Command, err, stderr.String())
+	}
+
+	var cred execCredential
+	if err := json.Unmarshal(stdout.Bytes(), &cred); err != nil {
+		return nil, fmt.Errorf("failed to decode output of exec plugin %s: %w", execConfig.Command, err)
+	}
+	if cred.Status.Token == "" {
+		return nil, fmt.Errorf("exec plugin %s returned no token", execConfig.Command)
+	}
+
+	return &cred, nil
+}
+
+// execCacheRoundTripper sets the Authorization header from an ExecCredentialCache.
+type execCacheRoundTripper struct {
+	cache      *ExecCredentialCache
+	execConfig *clientcmdapi.ExecConfig
+	next       http.RoundTripper
+}
+
+func (rt *execCacheRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Leave requests that already carry credentials untouched
+	if req.Header.Get("Authorization") != "" {
+		return rt.next.RoundTrip(req)
+	}
+
+	token, err := rt.cache.Token(req.Context(), rt.execConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := rt.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		if invalidateErr := rt.cache.Invalidate(rt.execConfig); invalidateErr != nil {
+			Logger.Warn("Failed to invalidate cached exec credential", "error", invalidateErr)
+		}
+	}
+
+	return resp, err
+}
+
+// WrappedRoundTripper returns the round tripper this one delegates to.
+func (rt *execCacheRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.next
+}
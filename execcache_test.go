@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// newCountingExecPlugin writes a plugin script printing a token that expires in an hour.
+// Each run appends the KUBERNETES_EXEC_INFO it received to the returned log file.
+func newCountingExecPlugin(t *testing.T) (*clientcmdapi.ExecConfig, string) {
+	t.Helper()
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "runs.log")
+	expiry := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	script := "#!/bin/sh\n" +
+		"echo \"$KUBERNETES_EXEC_INFO\" >> " + logFile + "\n" +
+		`echo '{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential",` +
+		`"status":{"token":"plugin-token","expirationTimestamp":"` + expiry + `"}}'` + "\n"
+	command := filepath.Join(dir, "plugin.sh")
+	if err := os.WriteFile(command, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return &clientcmdapi.ExecConfig{Command: command}, logFile
+}
+
+// pluginRuns returns the KUBERNETES_EXEC_INFO of every plugin run logged to logFile.
+func pluginRuns(t *testing.T, logFile string) []string {
+	t.Helper()
+	data, err := os.ReadFile(logFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestExecCredentialCacheToken(t *testing.T) {
+	execConfig, logFile := newCountingExecPlugin(t)
+	cache := &ExecCredentialCache{Dir: t.TempDir()}
+
+	for range 3 {
+		token, err := cache.Token(context.Background(), execConfig)
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		if token != "plugin-token" {
+			t.Errorf("Token() = %q, want %q", token, "plugin-token")
+		}
+	}
+
+	runs := pluginRuns(t, logFile)
+	if len(runs) != 1 {
+		t.Fatalf("plugin ran %d times, want 1", len(runs))
+	}
+	if !strings.Contains(runs[0], `"apiVersion":"`+ExecAPIVersionV1+`"`) {
+		t.Errorf("plugin received %s, want apiVersion %s", runs[0], ExecAPIVersionV1)
+	}
+}
+
+func TestExecCredentialCacheTokenInMemory(t *testing.T) {
+	execConfig, logFile := newCountingExecPlugin(t)
+	cache := &ExecCredentialCache{Dir: t.TempDir()}
+	if _, err := cache.Token(context.Background(), execConfig); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	// A fresh token is served from memory, without reading the cache file
+	if err := os.RemoveAll(cache.Dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Token(context.Background(), execConfig); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if runs := pluginRuns(t, logFile); len(runs) != 1 {
+		t.Errorf("plugin ran %d times, want 1", len(runs))
+	}
+
+	// Invalidate drops the in-memory token too
+	if err := cache.Invalidate(execConfig); err != nil {
+		t.Fatalf("Invalidate() error = %v", err)
+	}
+	if _, err := cache.Token(context.Background(), execConfig); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if runs := pluginRuns(t, logFile); len(runs) != 2 {
+		t.Errorf("plugin ran %d times after Invalidate, want 2", len(runs))
+	}
+}
+
+func TestExecCredentialCacheTokenFromFile(t *testing.T) {
+	execConfig, logFile := newCountingExecPlugin(t)
+	dir := t.TempDir()
+	if _, err := (&ExecCredentialCache{Dir: dir}).Token(context.Background(), execConfig); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	// A new cache, as in a restarted process, reads the token from the file
+	if _, err := (&ExecCredentialCache{Dir: dir}).Token(context.Background(), execConfig); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if runs := pluginRuns(t, logFile); len(runs) != 1 {
+		t.Errorf("plugin ran %d times, want 1", len(runs))
+	}
+}
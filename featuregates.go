@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// featureEnabledMetric is the API server metric reporting the state of each feature gate,
+// one sample per gate: kubernetes_feature_enabled{name="Gate",stage="BETA"} 1
+const featureEnabledMetric = "kubernetes_feature_enabled"
+
+// ErrFeatureGatesForbidden is returned by GetFeatureGates when the client is not allowed
+// to read the API server's /metrics endpoint.
+var ErrFeatureGatesForbidden = errors.New(
+	"reading feature gates requires get permission on the /metrics non-resource URL",
+)
+
+// GetFeatureGates reports which feature gates are enabled on the API server, keyed by gate name.
+//
+// This is best-effort: it reads the kubernetes_feature_enabled metric from the API
+// server's /metrics endpoint, which is only served on Kubernetes 1.26 and newer and is
+// commonly restricted by RBAC or hidden entirely on managed control planes. A cluster
+// that exposes no such metric yields an error rather than an empty map, so callers can
+// distinguish "unknown" from "all gates disabled".
+//
+// Parameters:
+//
+//	ctx: The context used for the metrics request.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//
+// Returns:
+//
+//	A map from feature gate name to whether it is enabled.
+//	An error wrapping ErrFeatureGatesForbidden if the client may not read /metrics, or
+//	another error if the request fails or the metric is not exposed.
+func GetFeatureGates(ctx context.Context, clientset kubernetes.Interface) (map[string]bool, error) {
+	restClient := clientset.Discovery().RESTClient()
+	if restClient == nil {
+		return nil, fmt.Errorf("failed to read API server metrics: the clientset cannot send raw requests")
+	}
+	body, err := restClient.Get().AbsPath("/metrics").DoRaw(ctx)
+	if err != nil {
+		if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) {
+			return nil, fmt.Errorf("%w: %w", ErrFeatureGatesForbidden, err)
+		}
+		return nil, fmt.Errorf("failed to read API server metrics: %w", err)
+	}
+
+	gates := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, featureEnabledMetric+"{") {
+			continue
+		}
+
+		labelsEnd := strings.LastIndex(line, "}")
+		if labelsEnd < 0 {
+			continue
+		}
+		name := metricLabel(line[len(featureEnabledMetric)+1:labelsEnd], "name")
+		if name == "" {
+			continue
+		}
+		value := strings.TrimSpace(line[labelsEnd+1:])
+		gates[name] = value == "1"
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse API server metrics: %w", err)
+	}
+
+	if len(gates) == 0 {
+		return nil, fmt.Errorf("API server does not expose the %s metric", featureEnabledMetric)
+	}
+
+	return gates, nil
+}
+
+// metricLabel extracts the value of label key from a Prometheus text-format label set
+// such as `name="Gate",stage="BETA"`. Feature gate names never contain quotes or commas,
+// so a simple split is sufficient.
+func metricLabel(labelSet, key string) string {
+	for pair := range strings.SplitSeq(labelSet, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.Trim(strings.TrimSpace(v), `"`)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// inClusterName is the cluster name reported for clients built by CreateInClusterKubeRestClient.
+const inClusterName = "in-cluster"
+
+var (
+	connectedHooksMu sync.RWMutex
+	connectedHooks   []func(clusterName string, serverVersion *version.Info)
+)
+
+// OnConnected registers hook to be called every time CreateExternalClusterKubeRestClient
+// or CreateInClusterKubeRestClient verifies a new connection, with the cluster's name and
+// the server version it reported. This lets callers register clients with a fleet
+// registry or pool at the moment of connection without wrapping the constructors.
+//
+// Hooks run synchronously, in registration order, before the constructor returns, so
+// they should be quick. They are not called for clients returned unverified (see
+// IsUnverified). Clients built in-cluster are reported with the name "in-cluster".
+//
+// Parameters:
+//
+//	hook: The function to call after each successful connection check.
+func OnConnected(hook func(clusterName string, serverVersion *version.Info)) {
+	connectedHooksMu.Lock()
+	defer connectedHooksMu.Unlock()
+	connectedHooks = append(connectedHooks, hook)
+}
+
+// notifyConnected calls every hook registered with OnConnected.
+func notifyConnected(clusterName string, serverVersion *version.Info) {
+	connectedHooksMu.RLock()
+	hooks := connectedHooks
+	connectedHooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(clusterName, serverVersion)
+	}
+}
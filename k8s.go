@@ -1,17 +1,56 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
+// Errors returned, wrapped, by BuildRestConfig and the constructors, so callers can decide
+// with errors.Is whether to retry, ask for reconfiguration, or give up. A configuration
+// that is not set at all is reported by GetK8sConfigs with ErrNoClustersConfigured.
+var (
+	// ErrMissingCredentials means a required client certificate or key is not configured.
+	ErrMissingCredentials = errors.New("missing credentials")
+
+	// ErrDecodeFailed means inline credential data is neither PEM nor valid base64.
+	ErrDecodeFailed = errors.New("failed to decode base64 data")
+
+	// ErrConnectionFailed means the connection check against the API server failed. The
+	// underlying error is wrapped too, so a transient network error can be told apart from
+	// an authentication failure, e.g. with apierrors.IsUnauthorized.
+	ErrConnectionFailed = errors.New("failed to connect to Kubernetes cluster")
+)
+
+// base64Encodings are the base64 variants accepted by decodeBase64, in the order they are
+// tried. Sources differ in whether they emit the URL-safe alphabet ('-' and '_' instead of
+// '+' and '/') and whether they pad the output with '='.
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.URLEncoding,
+	base64.RawStdEncoding,
+	base64.RawURLEncoding,
+}
+
 // decodeBase64 safely decodes a base64 encoded string.
 // It handles empty input strings by returning nil data and nil error.
-// If the input string is not empty but fails decoding, it returns an error
-// indicating the failure.
+// Standard and URL-safe base64 are both accepted, padded or unpadded.
+// If the input string is not empty but fails decoding with every variant,
+// it returns an error indicating the failure.
 //
 // Parameters:
 //
@@ -26,30 +65,44 @@ func decodeBase64(encodedData string) ([]byte, error) {
 		return nil, nil
 	}
 
-	// Use := for declaration and assignment
-	decodedData, err := base64.StdEncoding.DecodeString(encodedData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 data: %w", err)
+	var firstErr error
+	for _, encoding := range base64Encodings {
+		decodedData, err := encoding.DecodeString(encodedData)
+		if err == nil {
+			return decodedData, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
 	}
 
-	return decodedData, nil
+	// Report the standard encoding's error, which is what most inputs are meant to be.
+	return nil, fmt.Errorf("%w: %w", ErrDecodeFailed, firstErr)
 }
 
-// CreateExternalClusterKubeRestClient creates a Kubernetes clientset configured to connect
-// to a cluster from outside the cluster network (e.g., from a developer machine).
-// It uses the provided K8sConfig which contains the API server host URL and
-// base64 encoded TLS credentials (client certificate, client key, CA certificate).
+// BuildRestConfig builds a rest.Config for connecting to a cluster from outside the
+// cluster network, using the API server host URL and TLS credentials in the provided
+// K8sConfig. A host without a scheme defaults to https. It performs no network requests,
+// so the returned config can be used to build any client-go client (typed, dynamic,
+// discovery, metrics, and so on).
 //
-// This function first decodes the base64 encoded certificate data from the K8sConfig.
-// It requires all three data fields (CertData, KeyData, CAData) to be present and valid.
-// If any data is missing or fails decoding, it returns an error.
-//
-// After decoding, it constructs a rest.Config object using the host URL and TLS
-// configuration. This config is then used to create a kubernetes.Clientset.
-//
-// Finally, it performs a test query (fetching the server version) to verify the
-// connection to the cluster. If the connection is successful, it prints a success
-// message and returns the clientset. If the connection fails, it returns an error.
+// Each credential (client certificate, client key, CA certificate) is resolved
+// independently, so they may come from different sources: if the inline field (CertData,
+// KeyData, CAData) is set it is decoded from base64 (or used as is if it already holds
+// PEM), otherwise the matching file field (CertFile, KeyFile, CAFile) is passed to
+// client-go, which reads the file. Each credential must be provided by exactly one of
+// the two sources, and a file must exist. The client key is not required
+// when a PKCS#11 token holds it. If a bearer token (Token or TokenFile) is set, it is
+// used for authentication instead and any client certificate, key, or PKCS#11 settings
+// are ignored; the CA is still used to verify the server. An exec credential plugin
+// (Exec) likewise replaces the client certificate and key. The CA may be omitted for a
+// publicly trusted server and must be omitted with Insecure (see K8sConfig.Validate).
+// With Insecure and no client certificate or key, the config carries no credentials, for
+// anonymous access to a development cluster.
+// A client key encrypted with KeyPassphrase is decrypted here, so the returned config
+// never refers to the encrypted key. With PKCS11 or AppendSystemCAs the config carries a
+// custom transport holding the TLS settings, so NextProtos set on it afterwards have no
+// effect; the constructors' WithDisableHTTP2 option is passed to that transport.
 //
 // Parameters:
 //
@@ -58,71 +111,440 @@ func decodeBase64(encodedData string) ([]byte, error) {
 //
 // Returns:
 //
-//	A pointer to a configured kubernetes.Clientset ready for interacting with the cluster.
-//	An error if any step fails (decoding credentials, creating config, creating clientset,
-//	or connecting to the cluster).
-func CreateExternalClusterKubeRestClient(k8sconfig K8sConfig) (*kubernetes.Clientset, error) {
+//	A pointer to a rest.Config ready to be passed to a client-go client constructor.
+//	An error if the configuration fails Validate, or a credential is missing (wrapping
+//	ErrMissingCredentials) or fails decoding (wrapping ErrDecodeFailed).
+func BuildRestConfig(k8sconfig K8sConfig) (*rest.Config, error) {
+	return buildRestConfig(k8sconfig, false, nil)
+}
+
+// ValidateConfig checks k8sconfig as thoroughly as possible without contacting the API
+// server, for linting configurations in a pipeline without cluster access. It assembles
+// the rest.Config exactly as BuildRestConfig does, so validation and decoding errors are
+// the same, then parses the client certificate, key, and CA as client-go would on the first
+// TLS handshake, catching PEM data that decodes but does not parse and a key that does not
+// match its certificate. A PKCS#11 token is not opened.
+//
+// Parameters:
+//
+//	k8sconfig: A K8sConfig struct, typically read with GetK8sConfigs.
+//
+// Returns:
+//
+//	nil if a client could be built from k8sconfig.
+//	An error as returned by BuildRestConfig, or one describing the unusable credentials.
+func ValidateConfig(k8sconfig K8sConfig) error {
+	restConfig, err := buildRestConfig(k8sconfig, true, nil)
+	if err != nil {
+		return err
+	}
+
+	return checkTLSCredentials(restConfig.TLSClientConfig, k8sconfig.Name)
+}
+
+// checkTLSCredentials parses the client certificate and key, and the CA, of tlsConfig,
+// reading any files it refers to.
+func checkTLSCredentials(tlsConfig rest.TLSClientConfig, clusterName string) error {
+	certData, err := dataFromSliceOrFile(tlsConfig.CertData, tlsConfig.CertFile)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate file for cluster %s: %w", clusterName, err)
+	}
+	keyData, err := dataFromSliceOrFile(tlsConfig.KeyData, tlsConfig.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read key file for cluster %s: %w", clusterName, err)
+	}
+	caData, err := dataFromSliceOrFile(tlsConfig.CAData, tlsConfig.CAFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CA file for cluster %s: %w", clusterName, err)
+	}
+
+	switch {
+	case len(certData) > 0 && len(keyData) > 0:
+		if _, err := tls.X509KeyPair(certData, keyData); err != nil {
+			return fmt.Errorf("invalid client certificate or key for cluster %s: %w", clusterName, err)
+		}
+	case len(certData) > 0:
+		// The key is held by a PKCS#11 token, so only the certificate can be checked
+		block, _ := pem.Decode(certData)
+		if block == nil {
+			return fmt.Errorf("invalid client certificate for cluster %s: no PEM data found", clusterName)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("invalid client certificate for cluster %s: %w", clusterName, err)
+		}
+	}
+
+	if len(caData) > 0 && !x509.NewCertPool().AppendCertsFromPEM(caData) {
+		return fmt.Errorf("invalid CA data for cluster %s: no PEM certificates found", clusterName)
+	}
+	return nil
+}
+
+// buildClientRestConfig is BuildRestConfig with options applied, for the constructors.
+func buildClientRestConfig(k8sconfig K8sConfig, options clientOptions) (*rest.Config, error) {
+	restConfig, err := buildRestConfig(k8sconfig, false, options.nextProtos())
+	if err != nil {
+		return nil, err
+	}
+	options.apply(restConfig)
+	return restConfig, nil
+}
+
+// buildRestConfig implements BuildRestConfig. With dryRun, no custom transport is built and
+// no PKCS#11 token opened, leaving the credentials in the config's TLSClientConfig for
+// ValidateConfig to check. nextProtos are the TLS protocols to offer, or nil for the
+// default; they are set on the config and on the custom transport, which is built before
+// any option is applied and would otherwise ignore them.
+func buildRestConfig(k8sconfig K8sConfig, dryRun bool, nextProtos []string) (*rest.Config, error) {
+	if err := k8sconfig.Validate(); err != nil {
+		return nil, err
+	}
+	host, err := normalizeHost(k8sconfig.Host, k8sconfig.Name)
+	if err != nil {
+		return nil, err
+	}
+
 	var certData, keyData, caData []byte
-	var err error
+	var certFile, keyFile, caFile string
+	tlsConfig := k8sconfig.Config
+
+	// A bearer token or exec plugin takes precedence over client certificates, which are
+	// then ignored, and an insecure development connection may omit them
+	useClientCert := k8sconfig.usesClientCertificate()
+	usePKCS11 := tlsConfig.PKCS11 != nil && useClientCert
 
-	// Only attempt to decode if data is present
-	if k8sconfig.Config.CertData != "" {
-		certData, err = decodeBase64(k8sconfig.Config.CertData)
+	if useClientCert {
+		certData, certFile, err = resolveCredential(
+			tlsConfig.CertData, tlsConfig.CertFile, "certificate", k8sconfig.Name,
+		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode certificate data for cluster %s: %w", k8sconfig.Name, err)
+			return nil, err
 		}
-	} else {
-		return nil, fmt.Errorf("no certificate data provided for cluster %s", k8sconfig.Name)
 	}
 
-	if k8sconfig.Config.KeyData != "" {
-		keyData, err = decodeBase64(k8sconfig.Config.KeyData)
+	// A PKCS#11 key never leaves its token
+	if useClientCert && !usePKCS11 {
+		keyData, keyFile, err = resolveCredential(tlsConfig.KeyData, tlsConfig.KeyFile, "key", k8sconfig.Name)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode key data for cluster %s: %w", k8sconfig.Name, err)
+			return nil, err
+		}
+
+		// Decrypt a passphrase-protected key up front, since client-go only accepts plain keys
+		if tlsConfig.KeyPassphrase != "" {
+			if keyData == nil {
+				keyData, err = os.ReadFile(keyFile)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read key file for cluster %s: %w", k8sconfig.Name, err)
+				}
+				keyFile = ""
+			}
+			keyData, err = decryptPrivateKey(keyData, tlsConfig.KeyPassphrase)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt key for cluster %s: %w", k8sconfig.Name, err)
+			}
 		}
-	} else {
-		return nil, fmt.Errorf("no key data provided for cluster %s", k8sconfig.Name)
 	}
 
-	if k8sconfig.Config.CAData != "" {
-		caData, err = decodeBase64(k8sconfig.Config.CAData)
+	// Without a CA the system trust store verifies the server, which Validate has allowed
+	if tlsConfig.CAData != "" || tlsConfig.CAFile != "" {
+		caData, caFile, err = resolveCredential(tlsConfig.CAData, tlsConfig.CAFile, "CA", k8sconfig.Name)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode CA data for cluster %s: %w", k8sconfig.Name, err)
+			return nil, err
 		}
-	} else {
-		return nil, fmt.Errorf("no ca certificate data provided for cluster %s", k8sconfig.Name)
 	}
 
-	// Directly create REST config from K8sConfig fields
+	// Directly create REST config from K8sConfig fields. Each credential is set either
+	// as data or as a file path, never both.
 	restConfig := &rest.Config{
-		Host: k8sconfig.Host,
+		Host:            host,
+		BearerToken:     tlsConfig.Token,
+		BearerTokenFile: tlsConfig.TokenFile,
 		TLSClientConfig: rest.TLSClientConfig{
-			Insecure: k8sconfig.Config.Insecure,
-			CertData: certData,
-			KeyData:  keyData,
-			CAData:   caData,
+			Insecure:   tlsConfig.Insecure,
+			ServerName: tlsConfig.ServerName,
+			CertData:   certData,
+			CertFile:   certFile,
+			KeyData:    keyData,
+			KeyFile:    keyFile,
+			CAData:     caData,
+			CAFile:     caFile,
+			NextProtos: nextProtos,
 		},
+		Impersonate: rest.ImpersonationConfig{
+			UserName: k8sconfig.Impersonate.UserName,
+			Groups:   k8sconfig.Impersonate.Groups,
+			Extra:    k8sconfig.Impersonate.Extra,
+		},
+	}
+	if restConfig.BearerToken != "" {
+		restConfig.BearerTokenFile = ""
+	}
+	if k8sconfig.Exec != nil {
+		restConfig.ExecProvider = k8sconfig.Exec.clientcmdExecConfig()
+	}
+
+	// A nil Proxy makes client-go fall back to the proxy environment variables
+	proxyURL, err := parseProxyURL(k8sconfig.ProxyURL, k8sconfig.Name)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL != nil {
+		restConfig.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if err := applyContentType(restConfig, k8sconfig.ContentType); err != nil {
+		return nil, fmt.Errorf("invalid content type for cluster %s: %w", k8sconfig.Name, err)
+	}
+
+	// Sign with the PKCS#11 token, or trust the CA alongside the system roots, by replacing
+	// client-go's TLS handling with our own transport
+	appendSystemCAs := tlsConfig.AppendSystemCAs && (caData != nil || caFile != "")
+	if (usePKCS11 || appendSystemCAs) && !dryRun {
+		var transport http.RoundTripper
+		if usePKCS11 {
+			transport, err = newPKCS11Transport(tlsConfig.PKCS11, restConfig.TLSClientConfig, appendSystemCAs,
+				restConfig.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure pkcs11 client key for cluster %s: %w", k8sconfig.Name, err)
+			}
+		} else {
+			transport, err = newTLSTransport(restConfig.TLSClientConfig, nil, true, restConfig.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure TLS for cluster %s: %w", k8sconfig.Name, err)
+			}
+		}
+		restConfig.TLSClientConfig = rest.TLSClientConfig{}
+		restConfig.Transport = transport
+	}
+
+	return restConfig, nil
+}
+
+// pemPrefix starts every PEM block. Base64 encoded credentials start with "LS0t" (encoded
+// PEM) or 'M' (encoded DER), so inline credential data starting with it cannot be mistaken
+// for base64, even in the URL-safe alphabet, which uses '-'.
+const pemPrefix = "-----BEGIN"
+
+// resolveCredential resolves one TLS credential from its inline data or, if that is
+// empty, its file path, which must exist. Inline data may be base64 encoded or raw PEM.
+// Exactly one of the returned data and path is set.
+//
+// Parameters:
+//
+//	data: The inline base64 encoded or PEM credential, or "".
+//	file: The path of a PEM file holding the credential, or "".
+//	name: The credential's name for error messages, e.g. "certificate".
+//	clusterName: The cluster's name for error messages.
+//
+// Returns:
+//
+//	The decoded data, or nil if the file is used.
+//	The file path, or "" if the data is used.
+//	An error if neither or both are set, the file does not exist, or the data cannot be
+//	decoded.
+func resolveCredential(data, file, name, clusterName string) ([]byte, string, error) {
+	switch {
+	case data != "" && file != "":
+		return nil, "", fmt.Errorf("both %s data and a %s file provided for cluster %s, set only one",
+			name, name, clusterName)
+	case file != "":
+		// client-go only reads the file when the first client is built, so check it exists now
+		if _, err := os.Stat(file); err != nil {
+			return nil, "", fmt.Errorf("invalid %s file for cluster %s: %w", name, clusterName, err)
+		}
+		return nil, file, nil
+	case data == "":
+		return nil, "", fmt.Errorf("%w: no %s data provided for cluster %s", ErrMissingCredentials, name, clusterName)
+	}
+
+	// Raw PEM is passed through as is, so it need not be base64 encoded a second time
+	if strings.HasPrefix(strings.TrimSpace(data), pemPrefix) {
+		return []byte(data), "", nil
+	}
+
+	decoded, err := decodeBase64(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode %s data for cluster %s: %w", name, clusterName, err)
+	}
+	return decoded, "", nil
+}
+
+// NewProxyConfig builds a rest.Config that talks to the API server through a local
+// `kubectl proxy`, which authenticates every request with the developer's own kubeconfig
+// credentials. The returned config carries no credentials of its own and does not verify
+// TLS, so it is a convenience for local development only and must not be used in
+// production. A warning is logged to that effect.
+//
+// Parameters:
+//
+//	proxyURL: The URL the proxy listens on, e.g. "http://127.0.0.1:8001".
+//
+// Returns:
+//
+//	A pointer to a rest.Config pointing at the proxy.
+//	An error if proxyURL is not an absolute http or https URL.
+func NewProxyConfig(proxyURL string) (*rest.Config, error) {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubectl proxy URL %q: %w", proxyURL, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid kubectl proxy URL %q: must be an http or https URL with a host", proxyURL)
+	}
+
+	Logger.Warn("Using kubectl proxy without credentials, for local development only", "url", proxyURL)
+
+	return &rest.Config{
+		Host:            proxyURL,
+		TLSClientConfig: rest.TLSClientConfig{Insecure: parsed.Scheme == "https"},
+	}, nil
+}
+
+// CreateExternalClusterKubeRestClient is CreateExternalClusterKubeRestClientContext with
+// context.Background(), so the connection check is bounded only by client-go's own timeouts.
+func CreateExternalClusterKubeRestClient(k8sconfig K8sConfig, opts ...Option) (*kubernetes.Clientset, error) {
+	return CreateExternalClusterKubeRestClientContext(context.Background(), k8sconfig, opts...)
+}
+
+// CreateExternalClusterKubeRestClientContext creates a Kubernetes clientset configured
+// to connect to a cluster from outside the cluster network (e.g., from a developer machine).
+// It uses the provided K8sConfig which contains the API server host URL and
+// TLS credentials (client certificate, client key, CA certificate).
+//
+// This function first calls BuildRestConfig to resolve the credentials, each of which
+// may be given as inline base64 data or as a file path. If any credential is missing
+// or fails decoding, it returns an error. The resulting rest.Config is then used to
+// create a kubernetes.Clientset.
+//
+// Finally, it performs a test query (fetching the server version), bounded by ctx, to
+// verify the connection to the cluster. If the connection is successful, it logs a
+// success message to Logger and returns the clientset. If the connection fails, it returns an error.
+// With WithConnectRetries, transient failures such as a refused connection are first
+// retried with exponential backoff; by default a single attempt is made.
+// If the cluster denies or hides the version endpoint (403 or 404), as some locked-down
+// clusters do, a warning is logged and the clientset is returned unverified; see
+// IsUnverified. The check is skipped entirely with WithSkipConnectionCheck.
+//
+// The concrete clientset is returned, as IsUnverified and CloseIdleConnections need it,
+// but it satisfies kubernetes.Interface, which every helper in this package accepts. Code
+// that takes kubernetes.Interface can be unit tested with k8s.io/client-go/kubernetes/fake.
+//
+// Parameters:
+//
+//	ctx: The context bounding the connection check. If it expires first, the returned
+//	     error wraps context.DeadlineExceeded.
+//	k8sconfig: A K8sConfig struct containing the connection details and credentials
+//	           for the target Kubernetes cluster.
+//	opts: Options controlling how the client is built, such as WithTimeout. None are
+//	      needed for the default behaviour.
+//
+// Returns:
+//
+//	A pointer to a configured kubernetes.Clientset ready for interacting with the cluster.
+//	An error if any step fails (decoding credentials, creating config, creating clientset,
+//	or connecting to the cluster). Missing or undecodable credentials are reported as by
+//	BuildRestConfig, and a failed connection check wraps ErrConnectionFailed.
+func CreateExternalClusterKubeRestClientContext(
+	ctx context.Context,
+	k8sconfig K8sConfig,
+	opts ...Option,
+) (*kubernetes.Clientset, error) {
+	clientset, _, err := CreateExternalClusterKubeRestClientWithVersion(ctx, k8sconfig, opts...)
+	return clientset, err
+}
+
+// CreateExternalClusterKubeRestClientWithVersion is CreateExternalClusterKubeRestClientContext
+// that also returns the server version read by the connection check, so callers can gate
+// features on the cluster version without another round-trip. The version is nil when
+// the clientset is returned unverified (see IsUnverified).
+func CreateExternalClusterKubeRestClientWithVersion(
+	ctx context.Context,
+	k8sconfig K8sConfig,
+	opts ...Option,
+) (*kubernetes.Clientset, *ServerVersion, error) {
+	options := newClientOptions(opts)
+	restConfig, err := buildClientRestConfig(k8sconfig, options)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Create a Kubernetes clientset using the REST config
 	clientset, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
 		// Updated error message
-		return nil, fmt.Errorf("failed to create Kubernetes clientset for cluster %s: %w", k8sconfig.Name, err)
+		return nil, nil, fmt.Errorf("failed to create Kubernetes clientset for cluster %s: %w", k8sconfig.Name, err)
+	}
+	setDefaultNamespace(clientset, k8sconfig.Namespace)
+
+	if options.skipConnectionCheck {
+		markUnverified(clientset)
+		return clientset, nil, nil
 	}
 
 	// Run a test query to ensure the clientset is working
-	_, err = clientset.Discovery().ServerVersion()
+	serverVersion, err := verifyServerVersionWithRetry(ctx, clientset, k8sconfig.Name, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Kubernetes cluster %s: %w", k8sconfig.Name, err)
+		return nil, nil, fmt.Errorf("%w %s: %w", ErrConnectionFailed, k8sconfig.Name, err)
+	} else if serverVersion == nil {
+		return clientset, nil, nil
 	} else {
-		fmt.Printf("Successfully connected to Kubernetes cluster %s\n", k8sconfig.Name)
+		options.log().Info("Successfully connected to Kubernetes cluster", "cluster", k8sconfig.Name)
+		notifyConnected(k8sconfig.Name, serverVersion)
 	}
 
-	return clientset, nil
+	// Rebuild the clientset in JSON if CBOR was requested from a server too old to serve it
+	if downgradeCBOR(restConfig, serverVersion) {
+		clientset, err = kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create Kubernetes clientset for cluster %s: %w",
+				k8sconfig.Name, err)
+		}
+		setDefaultNamespace(clientset, k8sconfig.Namespace)
+	}
+
+	return clientset, newServerVersion(serverVersion), nil
+}
+
+// CreateExternalClusterDynamicClient creates a dynamic client for a cluster outside the
+// cluster network, for working with custom resources and any other resource by its
+// GroupVersionResource. It builds the rest.Config with BuildRestConfig, exactly as
+// CreateExternalClusterKubeRestClient does, so the same K8S_CONFIG setup applies.
+//
+// Unlike CreateExternalClusterKubeRestClient, no connection check is made; the first
+// request reports any connection problem. Use TestConnectivity to check up front.
+//
+// Parameters:
+//
+//	k8sconfig: A K8sConfig struct containing the connection details and credentials
+//	           for the target Kubernetes cluster.
+//	opts: Options controlling how the client is built. WithSkipConnectionCheck and the
+//	      retry options have no effect.
+//
+// Returns:
+//
+//	A dynamic.Interface ready for listing, watching, and modifying arbitrary resources.
+//	An error if the rest.Config or the client cannot be created.
+func CreateExternalClusterDynamicClient(k8sconfig K8sConfig, opts ...Option) (dynamic.Interface, error) {
+	restConfig, err := buildClientRestConfig(k8sconfig, newClientOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client for cluster %s: %w", k8sconfig.Name, err)
+	}
+
+	return dynamicClient, nil
 }
 
-// CreateInClusterKubeRestClient creates a Kubernetes clientset configured to run
+// CreateInClusterKubeRestClient is CreateInClusterKubeRestClientContext with
+// context.Background(), so the connection check is bounded only by client-go's own timeouts.
+func CreateInClusterKubeRestClient(opts ...Option) (*kubernetes.Clientset, error) {
+	return CreateInClusterKubeRestClientContext(context.Background(), opts...)
+}
+
+// CreateInClusterKubeRestClientContext creates a Kubernetes clientset configured to run
 // from within a Kubernetes cluster (e.g., inside a pod).
 // It automatically uses the service account token and CA certificate mounted
 // into the pod by Kubernetes, requiring no explicit configuration parameters.
@@ -131,39 +553,185 @@ func CreateExternalClusterKubeRestClient(k8sconfig K8sConfig) (*kubernetes.Clien
 // by the Kubernetes environment (service account token, API server host/port from
 // environment variables, and the cluster's CA certificate).
 //
-// It then uses this configuration to create a kubernetes.Clientset.
+// It then uses this configuration to create a kubernetes.Clientset. The service account
+// token is read from its mounted file rather than once at startup, so clients in
+// long-running pods keep working as projected tokens expire and are rotated.
+//
+// Similar to CreateExternalClusterKubeRestClientContext, it performs a test query
+// (fetching the server version), bounded by ctx, to verify the connection. If
+// successful, it logs a success message and returns the clientset. If any step fails
+// (loading in-cluster config, creating clientset, or connecting), it returns an error.
+// Transient connection failures are first retried as configured by opts.
+// A 403 or 404 from the version endpoint yields an unverified clientset rather than an
+// error. The check is skipped entirely with WithSkipConnectionCheck.
 //
-// Similar to CreateExternalClusterKubeRestClient, it performs a test query (fetching the
-// server version) to verify the connection. If successful, it prints a success
-// message and returns the clientset. If any step fails (loading in-cluster config,
-// creating clientset, or connecting), it returns an error.
+// Parameters:
+//
+//	ctx: The context bounding the connection check. If it expires first, the returned
+//	     error wraps context.DeadlineExceeded.
+//	opts: Options controlling how the client is built, such as WithTimeout. None are
+//	      needed for the default behaviour.
 //
 // Returns:
 //
 //	A pointer to a configured kubernetes.Clientset ready for interacting with the cluster.
 //	An error if it fails to load the in-cluster configuration, create the clientset,
-//	or connect to the cluster API server.
-func CreateInClusterKubeRestClient() (*kubernetes.Clientset, error) {
-	// Create a Kubernetes client using in-cluster configuration
+//	or connect to the cluster API server, which wraps ErrConnectionFailed.
+func CreateInClusterKubeRestClientContext(ctx context.Context, opts ...Option) (*kubernetes.Clientset, error) {
+	clientset, _, err := CreateInClusterKubeRestClientWithVersion(ctx, opts...)
+	return clientset, err
+}
+
+// CreateInClusterKubeRestClientWithVersion is CreateInClusterKubeRestClientContext that
+// also returns the server version read by the connection check. The version is nil when
+// the clientset is returned unverified (see IsUnverified).
+func CreateInClusterKubeRestClientWithVersion(
+	ctx context.Context,
+	opts ...Option,
+) (*kubernetes.Clientset, *ServerVersion, error) {
+	// Create a Kubernetes client using in-cluster configuration. It sets BearerTokenFile
+	// to the mounted token, which client-go re-reads every minute, so rotated projected
+	// tokens are picked up; the BearerToken read here only serves until the first re-read.
 	config, err := rest.InClusterConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create in-cluster config: %w", err)
+		return nil, nil, fmt.Errorf("failed to create in-cluster config: %w", err)
 	}
+	return newInClusterKubeRestClient(ctx, config, opts)
+}
+
+// newInClusterKubeRestClient builds and verifies a clientset from config, as loaded by
+// rest.InClusterConfig, for CreateInClusterKubeRestClientWithVersion and
+// CreateKubeRestClientContext.
+func newInClusterKubeRestClient(
+	ctx context.Context,
+	config *rest.Config,
+	opts []Option,
+) (*kubernetes.Clientset, *ServerVersion, error) {
+	options := newClientOptions(opts)
+	options.apply(config)
 
 	// Create a Kubernetes clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+		return nil, nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+	}
+	setDefaultNamespace(clientset, inClusterNamespace())
+
+	if options.skipConnectionCheck {
+		markUnverified(clientset)
+		return clientset, nil, nil
 	}
 
 	// Verify the connection to the Kubernetes cluster
-	_, err = clientset.Discovery().ServerVersion()
+	serverVersion, err := verifyServerVersionWithRetry(ctx, clientset, inClusterName, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Kubernetes cluster: %w", err)
-	} else {
-		fmt.Printf("Successfully connected to Kubernetes cluster")
+		return nil, nil, fmt.Errorf("%w: %w", ErrConnectionFailed, err)
+	} else if serverVersion != nil {
+		options.log().Info("Successfully connected to Kubernetes cluster", "cluster", inClusterName)
+		notifyConnected(inClusterName, serverVersion)
 	}
 
 	// Return the clientset
+	return clientset, newServerVersion(serverVersion), nil
+}
+
+// CreateKubeRestClient is CreateKubeRestClientContext with context.Background(), so the
+// connection check is bounded only by client-go's own timeouts.
+func CreateKubeRestClient(opts ...Option) (*kubernetes.Clientset, error) {
+	return CreateKubeRestClientContext(context.Background(), opts...)
+}
+
+// CreateKubeRestClientContext creates a Kubernetes clientset for whichever environment the
+// process runs in, so one binary works both in a pod and on a developer machine.
+//
+// It first tries the in-cluster configuration. If the process is not running in a pod
+// (rest.InClusterConfig returns rest.ErrNotInCluster), it falls back to the external
+// configuration read by GetK8sConfigs and connects with
+// CreateExternalClusterKubeRestClientContext. Any other in-cluster failure, such as an
+// unreadable service account token, is returned rather than masked by the fallback.
+//
+// Parameters:
+//
+//	ctx: The context bounding the connection check. If it expires first, the returned
+//	     error wraps context.DeadlineExceeded.
+//	opts: Options passed to whichever constructor is used.
+//
+// Returns:
+//
+//	A pointer to a configured kubernetes.Clientset ready for interacting with the cluster.
+//	An error naming the path (in-cluster or external) that was attempted and why it failed.
+func CreateKubeRestClientContext(ctx context.Context, opts ...Option) (*kubernetes.Clientset, error) {
+	config, err := rest.InClusterConfig()
+	if err == nil {
+		clientset, _, err := newInClusterKubeRestClient(ctx, config, opts)
+		if err != nil {
+			return nil, fmt.Errorf("running in-cluster: %w", err)
+		}
+		return clientset, nil
+	}
+	if !errors.Is(err, rest.ErrNotInCluster) {
+		return nil, fmt.Errorf("running in-cluster but the in-cluster configuration is invalid: %w", err)
+	}
+
+	k8sconfig, err := GetK8sConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("not running in-cluster and no external cluster configured: %w", err)
+	}
+	clientset, err := CreateExternalClusterKubeRestClientContext(ctx, k8sconfig, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("not running in-cluster, external cluster connection failed: %w", err)
+	}
 	return clientset, nil
 }
+
+// defaultWaitInterval is the delay WaitForAPIServer uses between connection attempts when
+// given a non-positive interval.
+const defaultWaitInterval = time.Second
+
+// WaitForAPIServer blocks until a TCP connection can be opened to the Kubernetes API
+// server advertised to the pod, or until ctx is done. It is intended to run before
+// CreateInClusterKubeRestClient at pod startup, when the API server may be briefly
+// unreachable (for example during a control plane upgrade), so the process waits
+// instead of crash-looping.
+//
+// The API server address is read from the KUBERNETES_SERVICE_HOST and
+// KUBERNETES_SERVICE_PORT environment variables that Kubernetes injects into every pod.
+// Only TCP reachability is checked; no TLS handshake or authentication is attempted.
+//
+// Parameters:
+//
+//	ctx: The context bounding how long to wait.
+//	interval: The delay between connection attempts. It also bounds each dial attempt.
+//	          Zero or a negative value uses one second.
+//
+// Returns:
+//
+//	nil once the API server accepts a TCP connection.
+//	rest.ErrNotInCluster if the service environment variables are not set.
+//	An error wrapping the context error and the last dial error if ctx is done first.
+func WaitForAPIServer(ctx context.Context, interval time.Duration) error {
+	address, ok := inClusterServiceAddress()
+	if !ok {
+		return rest.ErrNotInCluster
+	}
+	if interval <= 0 {
+		interval = defaultWaitInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		dialer := net.Dialer{Timeout: interval}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			return conn.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("API server %s not reachable: %w (last error: %v)", address, ctx.Err(), err)
+		case <-ticker.C:
+		}
+	}
+}
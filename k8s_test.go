@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// TestTokenFileRotation simulates the kubelet rotating a projected service account token by
+// rewriting the token file, and checks that later requests carry the new token. client-go
+// caches the file contents for up to a minute, so the test takes about that long.
+func TestTokenFileRotation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for client-go to re-read the token file")
+	}
+	t.Parallel()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("old-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu        sync.Mutex
+		lastToken string
+	)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastToken = r.Header.Get("Authorization")
+		mu.Unlock()
+		if _, err := w.Write([]byte("ok")); err != nil {
+			t.Errorf("failed to write response: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	k8sconfig := K8sConfig{
+		Name:   "test",
+		Host:   server.URL,
+		Config: TLSClientConfig{Insecure: true, TokenFile: tokenFile},
+	}
+	clientset, err := CreateExternalClusterKubeRestClient(k8sconfig, WithSkipConnectionCheck())
+	if err != nil {
+		t.Fatalf("failed to create clientset: %v", err)
+	}
+	requestToken := func() string {
+		if err := Ping(context.Background(), clientset); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return lastToken
+	}
+
+	if got := requestToken(); got != "Bearer old-token" {
+		t.Fatalf("Authorization before rotation = %q, want %q", got, "Bearer old-token")
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("new-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(90 * time.Second)
+	for {
+		got := requestToken()
+		if got == "Bearer new-token" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Authorization after rotation = %q, want %q", got, "Bearer new-token")
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func TestDecodeBase64(t *testing.T) {
+	// 0xfb 0xff 0xfe encodes to "+//+" in standard and "-__-" in URL-safe base64, and the
+	// trailing two bytes need padding.
+	data := []byte{0xfb, 0xff, 0xfe, 0x01, 0x02}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "empty", input: ""},
+		{name: "standard padded", input: "+//+AQI=", want: data},
+		{name: "standard unpadded", input: "+//+AQI", want: data},
+		{name: "URL-safe padded", input: "-__-AQI=", want: data},
+		{name: "URL-safe unpadded", input: "-__-AQI", want: data},
+		{name: "invalid characters", input: "not base64!", wantErr: true},
+		{name: "mixed alphabets", input: "+_/-AQI=", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeBase64(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrDecodeFailed) {
+					t.Errorf("decodeBase64(%q) error = %v, want %v", tt.input, err, ErrDecodeFailed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeBase64(%q) error = %v", tt.input, err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decodeBase64(%q) = %x, want %x", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitForAPIServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := listener.Close(); err != nil {
+			t.Errorf("failed to close listener: %v", err)
+		}
+	})
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBERNETES_SERVICE_HOST", host)
+	t.Setenv("KUBERNETES_SERVICE_PORT", port)
+
+	// A non-positive interval falls back to the default instead of panicking
+	for _, interval := range []time.Duration{0, -time.Second, 10 * time.Millisecond} {
+		if err := WaitForAPIServer(context.Background(), interval); err != nil {
+			t.Errorf("WaitForAPIServer(%s) error = %v", interval, err)
+		}
+	}
+}
+
+func TestWaitForAPIServerNotInCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if err := WaitForAPIServer(context.Background(), 0); !errors.Is(err, rest.ErrNotInCluster) {
+		t.Errorf("WaitForAPIServer() error = %v, want %v", err, rest.ErrNotInCluster)
+	}
+}
+
+func TestCreateKubeRestClientContextExternal(t *testing.T) {
+	// The version endpoint never answers, so only ctx can end the connection check
+	server := httptest.NewTLSServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("K8S_HOST", server.URL)
+	t.Setenv("K8S_CONFIG_FILE", "")
+	t.Setenv("K8S_CONFIG", `{"tlsClientConfig":{"insecure":true}}`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := CreateKubeRestClientContext(ctx, WithConnectRetries(0))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CreateKubeRestClientContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// maxPaginationRestarts bounds how many times ListAll restarts a list whose continue
+// token expired, so a list that can never finish within the token lifetime fails
+// instead of looping forever.
+const maxPaginationRestarts = 3
+
+// defaultPageSize is the page size of the helpers that list every object of a resource,
+// such as ListAllPods, keeping each response bounded on large clusters.
+const defaultPageSize = 500
+
+// ErrPaginationExpired is returned by ListAll when the API server expires the continue
+// token (HTTP 410 Gone) before every page was read. Check for it with errors.Is.
+var ErrPaginationExpired = errors.New("list continue token expired before all pages were read")
+
+// ListFunc lists one page of objects. It is typically a thin closure over a typed or
+// dynamic client's List method, e.g.
+//
+//	func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+//		return clientset.CoreV1().Pods("default").List(ctx, opts)
+//	}
+type ListFunc func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error)
+
+// ListAllOptions controls how ListAll pages through a list.
+type ListAllOptions struct {
+	// RestartOnExpired makes ListAll start over from the first page when the continue
+	// token expires (HTTP 410 Gone), instead of returning ErrPaginationExpired.
+	//
+	// Continue tokens are only valid for as long as the API server retains the resource
+	// version they were issued at (about five minutes with default etcd compaction), so
+	// slow consumers of very large lists can outlive them. Restarting returns a complete
+	// and consistent snapshot but discards the work already done and may restart
+	// repeatedly on a list that is too slow to read; a bounded number of restarts is
+	// attempted before giving up with ErrPaginationExpired. Returning the error lets the
+	// caller decide instead, for example to process pages incrementally.
+	RestartOnExpired bool
+
+	// PageSize is the maximum number of items requested per page (ListOptions.Limit).
+	// Smaller pages lower the memory used by the API server per request at the cost of
+	// more round-trips. Zero lets the server choose, which typically returns everything
+	// in one page.
+	PageSize int64
+
+	// ResourceVersion and ResourceVersionMatch select the resource version the first page
+	// is read at, e.g. "0" with "NotOlderThan" to serve the list from the API server's
+	// cache. Later pages are always read at the resource version of the first page, as
+	// encoded in the continue token, so every page is from one consistent snapshot.
+	// Empty reads the most recent data from etcd.
+	ResourceVersion      string
+	ResourceVersionMatch metav1.ResourceVersionMatch
+}
+
+// ListAll calls listFunc repeatedly, following the continue token returned with each
+// page, until every page has been read, and returns all items of every page. An empty
+// list returns no items and no error.
+//
+// T is the item type of the list, e.g. corev1.Pod for a *corev1.PodList or
+// unstructured.Unstructured for an *unstructured.UnstructuredList.
+//
+// Parameters:
+//
+//	ctx: The context passed to every listFunc call.
+//	listFunc: The function listing a single page.
+//	opts: Controls the page size, the resource version read, and how an expired
+//	      continue token is handled.
+//
+// Returns:
+//
+//	All items across every page.
+//	An error wrapping ErrPaginationExpired if the continue token expired, or another
+//	error if a page fails to list or holds items of an unexpected type.
+func ListAll[T any](ctx context.Context, listFunc ListFunc, opts ListAllOptions) ([]T, error) {
+	items, _, err := listAllWithVersion[T](ctx, listFunc, opts)
+	return items, err
+}
+
+// listAllWithVersion is ListAll that also returns the resource version the list was read
+// at, from which a watch can continue.
+func listAllWithVersion[T any](ctx context.Context, listFunc ListFunc, opts ListAllOptions) ([]T, string, error) {
+	for restarts := 0; ; restarts++ {
+		items, resourceVersion, err := listAllPages[T](ctx, listFunc, opts)
+		if !errors.Is(err, ErrPaginationExpired) || !opts.RestartOnExpired || restarts >= maxPaginationRestarts {
+			return items, resourceVersion, err
+		}
+	}
+}
+
+// listAllPages reads every page of a list once, without restarting.
+func listAllPages[T any](ctx context.Context, listFunc ListFunc, opts ListAllOptions) ([]T, string, error) {
+	var items []T
+	listOpts := metav1.ListOptions{
+		Limit:                opts.PageSize,
+		ResourceVersion:      opts.ResourceVersion,
+		ResourceVersionMatch: opts.ResourceVersionMatch,
+	}
+	for {
+		list, err := listFunc(ctx, listOpts)
+		if err != nil {
+			if listOpts.Continue != "" && (apierrors.IsResourceExpired(err) || apierrors.IsGone(err)) {
+				return nil, "", fmt.Errorf("%w: %w", ErrPaginationExpired, err)
+			}
+			return nil, "", err
+		}
+
+		err = meta.EachListItem(list, func(obj runtime.Object) error {
+			item, ok := any(obj).(*T)
+			if !ok {
+				return fmt.Errorf("unexpected list item type %T", obj)
+			}
+			items = append(items, *item)
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
+		listMeta, err := meta.ListAccessor(list)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read list metadata: %w", err)
+		}
+		if listMeta.GetContinue() == "" {
+			return items, listMeta.GetResourceVersion(), nil
+		}
+		// The continue token pins the resource version, which must not be sent alongside it
+		listOpts.Continue = listMeta.GetContinue()
+		listOpts.ResourceVersion = ""
+		listOpts.ResourceVersionMatch = ""
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import "log/slog"
+
+// Logger receives every log message emitted by this package, such as connection
+// successes, fallbacks, and warnings. It discards everything by default, so embedding the
+// package in a larger service produces no unexpected output. Replace it before creating
+// any clients to route messages to your own handler, for example:
+//
+//	Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+//
+// Informational messages are logged at slog.LevelInfo, degraded behaviour (fallbacks,
+// unverified clients, slow requests) at slog.LevelWarn.
+var Logger = slog.New(slog.DiscardHandler)
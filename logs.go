@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// lockedWriter serializes writes from concurrent log streams so lines are never interleaved mid-line.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) writeLine(line string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err := io.WriteString(l.w, line)
+	return err
+}
+
+// containerTail tracks the log stream of a single container.
+type containerTail struct {
+	key    string
+	cancel context.CancelFunc
+}
+
+// podTailer multiplexes the log streams of every running container in the pods it is told about.
+type podTailer struct {
+	ctx       context.Context
+	clientset kubernetes.Interface
+	ns        string
+	prefix    bool
+	out       *lockedWriter
+
+	wg    sync.WaitGroup
+	done  chan *containerTail
+	tails map[string]*containerTail
+	ended map[string]time.Time
+}
+
+// sync starts a stream for every running container of pod that is not already tailed.
+func (t *podTailer) sync(pod *corev1.Pod) {
+	for i := range pod.Status.ContainerStatuses {
+		status := &pod.Status.ContainerStatuses[i]
+		key := pod.Name + "/" + status.Name
+		if status.State.Running == nil {
+			continue
+		}
+		if _, ok := t.tails[key]; ok {
+			continue
+		}
+
+		opts := &corev1.PodLogOptions{Container: status.Name, Follow: true}
+		if endedAt, ok := t.ended[key]; ok {
+			since := metav1.NewTime(endedAt)
+			opts.SinceTime = &since
+		}
+
+		tailCtx, cancel := context.WithCancel(t.ctx)
+		tail := &containerTail{key: key, cancel: cancel}
+		t.tails[key] = tail
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.stream(tailCtx, tail, pod.Name, opts)
+		}()
+	}
+}
+
+// stop cancels the streams of every container of the named pod.
+func (t *podTailer) stop(podName string) {
+	for key, tail := range t.tails {
+		if strings.HasPrefix(key, podName+"/") {
+			tail.cancel()
+			delete(t.tails, key)
+			delete(t.ended, key)
+		}
+	}
+}
+
+// finished records that a stream ended so it can be restarted by a later pod update. Streams
+// that were already replaced or stopped are ignored.
+func (t *podTailer) finished(tail *containerTail) {
+	tail.cancel()
+	if t.tails[tail.key] != tail {
+		return
+	}
+	delete(t.tails, tail.key)
+	t.ended[tail.key] = time.Now()
+}
+
+// follow applies pod events from watcher until the watch closes, so the caller can relist
+// and re-watch, or the tailer's context is done. It reports whether tailing should stop.
+func (t *podTailer) follow(watcher watch.Interface) bool {
+	for {
+		select {
+		case <-t.ctx.Done():
+			return true
+		case tail := <-t.done:
+			t.finished(tail)
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false
+			}
+
+			pod, isPod := event.Object.(*corev1.Pod)
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				if isPod {
+					t.sync(pod)
+				}
+			case watch.Deleted:
+				if isPod {
+					t.stop(pod.Name)
+				}
+			case watch.Error:
+				return false
+			case watch.Bookmark:
+			}
+		}
+	}
+}
+
+// stream copies one container's log stream to the shared writer line by line.
+func (t *podTailer) stream(ctx context.Context, tail *containerTail, podName string, opts *corev1.PodLogOptions) {
+	key := tail.key
+	defer func() {
+		select {
+		case t.done <- tail:
+		case <-t.ctx.Done():
+		}
+	}()
+
+	logs, err := t.clientset.CoreV1().Pods(t.ns).GetLogs(podName, opts).Stream(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			if err := t.out.writeLine(fmt.Sprintf("[%s] failed to open log stream: %v\n", key, err)); err != nil {
+				return
+			}
+		}
+		return
+	}
+	defer logs.Close()
+
+	reader := bufio.NewReader(logs)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			if t.prefix {
+				line = "[" + key + "] " + line
+			}
+			if err := t.out.writeLine(line); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// TailPodsByLabel follows the logs of every container in every pod matching selector in
+// namespace ns and writes them to w, interleaved line by line, until ctx is cancelled.
+//
+// Pods are watched for the lifetime of the call: log streams are opened as soon as a
+// matching pod's containers start running and closed when the pod is deleted. If a
+// container restarts, its stream is resumed from the time the previous stream ended so
+// lines are not repeated. Streams for pods that exist when tailing begins start from the
+// beginning of the containers' logs.
+//
+// Lines are written whole, one at a time, so output from different containers never mixes
+// within a line. When prefix is true, each line is prefixed with "[pod/container] ".
+//
+// Parameters:
+//
+//	ctx: The context controlling how long to tail. Cancelling it stops all streams.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	ns: The namespace holding the pods.
+//	selector: The label selector identifying the pods to tail.
+//	prefix: Whether to prefix each line with the pod and container it came from.
+//	w: The writer the log lines are written to.
+//
+// Returns:
+//
+//	nil when ctx is cancelled.
+//	An error if listing or watching the pods fails.
+func TailPodsByLabel(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	ns string,
+	selector labels.Selector,
+	prefix bool,
+	w io.Writer,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	tailer := &podTailer{
+		ctx:       ctx,
+		clientset: clientset,
+		ns:        ns,
+		prefix:    prefix,
+		out:       &lockedWriter{w: w},
+		done:      make(chan *containerTail),
+		tails:     map[string]*containerTail{},
+		ended:     map[string]time.Time{},
+	}
+	defer tailer.wg.Wait()
+	defer cancel()
+
+	opts := metav1.ListOptions{LabelSelector: selector.String()}
+	for {
+		pods, err := clientset.CoreV1().Pods(ns).List(ctx, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to list pods matching %q in namespace %s: %w", selector.String(), ns, err)
+		}
+
+		present := map[string]bool{}
+		for i := range pods.Items {
+			present[pods.Items[i].Name] = true
+			tailer.sync(&pods.Items[i])
+		}
+		for key := range tailer.tails {
+			if podName, _, _ := strings.Cut(key, "/"); !present[podName] {
+				tailer.stop(podName)
+			}
+		}
+
+		watchOpts := opts
+		watchOpts.ResourceVersion = pods.ResourceVersion
+		watcher, err := clientset.CoreV1().Pods(ns).Watch(ctx, watchOpts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to watch pods matching %q in namespace %s: %w", selector.String(), ns, err)
+		}
+
+		stop := tailer.follow(watcher)
+		watcher.Stop()
+		if stop {
+			return nil
+		}
+	}
+}
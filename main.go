@@ -2,11 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"os"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// examplePageSize is the number of items the examples request per page.
+const examplePageSize = 100
+
 func main() {
+	// route the package's log messages (connection successes, warnings) to stderr;
+	// they are discarded by default
+	Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
 	// example usage of CreateInClusterKubeRestClient
 	// no need to pass configuration as it uses the service account token automatically
 	// mounted in the pod by kubernetes. make sure this service account has
@@ -16,11 +28,16 @@ func main() {
 		panic(err)
 	}
 
-	pods, err := inClusterClientSet.CoreV1().Pods("default").List(context.TODO(), metav1.ListOptions{})
+	// ListAll follows continue tokens, so large namespaces are read in full, page by page
+	pods, err := ListAll[corev1.Pod](context.TODO(),
+		func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return inClusterClientSet.CoreV1().Pods("default").List(ctx, opts)
+		},
+		ListAllOptions{PageSize: examplePageSize})
 	if err != nil {
 		panic(err)
 	}
-	for _, pod := range pods.Items {
+	for _, pod := range pods {
 		println("In-Cluster Pod Name:", pod.Name)
 	}
 
@@ -36,14 +53,56 @@ func main() {
 		panic(err)
 	}
 
-	// example usage of the clientset to list service accounts in the "default" namespace and print their names
-	serviceAccounts, err := externalClusterClientSet.CoreV1().
-		ServiceAccounts("default").
-		List(context.TODO(), metav1.ListOptions{})
+	// example usage of the clientset to list service accounts in the cluster's default namespace
+	// (K8S_NAMESPACE, or "default") and print their names
+	namespace := DefaultNamespace(externalClusterClientSet)
+	serviceAccounts, err := ListAll[corev1.ServiceAccount](context.TODO(),
+		func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return externalClusterClientSet.CoreV1().ServiceAccounts(namespace).List(ctx, opts)
+		},
+		ListAllOptions{PageSize: examplePageSize})
 	if err != nil {
 		panic(err)
 	}
-	for _, sa := range serviceAccounts.Items {
+	for _, sa := range serviceAccounts {
 		println("External Cluster Service Account Name:", sa.Name)
 	}
+
+	// example usage of ListNodes to print the allocatable capacity of every node; listing
+	// nodes needs a ClusterRole, so a client limited to namespaced roles skips this
+	nodes, err := ListNodes(context.TODO(), externalClusterClientSet)
+	if errors.Is(err, ErrNodesForbidden) {
+		println("Skipping nodes:", err.Error())
+	} else if err != nil {
+		panic(err)
+	}
+	for _, node := range nodes {
+		println("External Cluster Node:", node.Name,
+			"CPU:", node.Status.Allocatable.Cpu().String(),
+			"Memory:", node.Status.Allocatable.Memory().String())
+	}
+
+	// example usage of CreateMetricsClient to print the CPU usage of the pods in the
+	// cluster's default namespace; it needs metrics-server to be installed in the cluster
+	restConfig, err := BuildRestConfig(k8sConfig)
+	if err != nil {
+		panic(err)
+	}
+	metricsClient, err := CreateMetricsClient(context.TODO(), restConfig)
+	if errors.Is(err, ErrMetricsAPIUnavailable) {
+		println("Skipping pod metrics:", err.Error())
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	podMetrics, err := metricsClient.MetricsV1beta1().PodMetricses(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		panic(err)
+	}
+	for _, podMetric := range podMetrics.Items {
+		for _, container := range podMetric.Containers {
+			println("External Cluster Pod CPU Usage:", podMetric.Name, container.Name, container.Usage.Cpu().String())
+		}
+	}
 }
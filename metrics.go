@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// metricsGroupVersionPath is the discovery path of the resource metrics API.
+const metricsGroupVersionPath = "/apis/metrics.k8s.io/v1beta1"
+
+// ErrMetricsAPIUnavailable is returned by CreateMetricsClient when the cluster does not
+// serve the metrics.k8s.io API, usually because metrics-server is not installed or not
+// yet ready. Check for it with errors.Is.
+var ErrMetricsAPIUnavailable = errors.New("the metrics.k8s.io API is not available, " +
+	"check that metrics-server is installed and running")
+
+// CreateMetricsClient creates a client for the metrics.k8s.io API, which reports the
+// current CPU and memory usage of pods and nodes (PodMetrics and NodeMetrics), from the
+// same rest.Config the other clients are built from.
+//
+// The API is only served when metrics-server (or another resource metrics provider) is
+// installed, so its presence is checked up front, bounded by ctx: without it every
+// request would fail with a bare "the server could not find the requested resource".
+//
+// Parameters:
+//
+//	ctx: The context bounding the availability check.
+//	restConfig: The REST configuration used to reach the API server, for example one
+//	            returned by BuildRestConfig or rest.InClusterConfig.
+//
+// Returns:
+//
+//	A pointer to a metrics clientset, e.g. for
+//	client.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{}).
+//	An error wrapping ErrMetricsAPIUnavailable if the API is not served, or another
+//	error if the client cannot be created or the check fails.
+func CreateMetricsClient(ctx context.Context, restConfig *rest.Config) (*metricsv.Clientset, error) {
+	metricsClient, err := metricsv.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics client: %w", err)
+	}
+
+	// A missing APIService is a 404; a registered one whose backend is down is a 503
+	err = metricsClient.Discovery().RESTClient().Get().AbsPath(metricsGroupVersionPath).Do(ctx).Error()
+	switch {
+	case apierrors.IsNotFound(err), apierrors.IsServiceUnavailable(err):
+		return nil, fmt.Errorf("%w: %w", ErrMetricsAPIUnavailable, err)
+	case err != nil:
+		return nil, fmt.Errorf("failed to check for the metrics.k8s.io API: %w", err)
+	}
+
+	return metricsClient, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"weak"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// inClusterNamespaceFile holds the namespace of the pod's service account, mounted into
+// every pod alongside its token.
+const inClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// clientsetNamespaces records the default namespace of each clientset built by the
+// constructors. Keys are weak, as for unverifiedClientsets.
+var clientsetNamespaces sync.Map // map[weak.Pointer[kubernetes.Clientset]]string
+
+// DefaultNamespace returns the namespace clientset should work in when the caller has no
+// more specific one, so a deployment's target namespace need not be threaded through
+// every call. It is K8sConfig.Namespace for a clientset built by
+// CreateExternalClusterKubeRestClient, and the pod's own namespace, read from its service
+// account, for one built by CreateInClusterKubeRestClient. When neither is known, for
+// example for a clientset built elsewhere, it is "default".
+//
+// Parameters:
+//
+//	clientset: A clientset returned by CreateExternalClusterKubeRestClient or
+//	           CreateInClusterKubeRestClient.
+//
+// Returns:
+//
+//	The clientset's default namespace, never empty.
+func DefaultNamespace(clientset *kubernetes.Clientset) string {
+	if namespace, ok := clientsetNamespaces.Load(weak.Make(clientset)); ok {
+		if namespace, isString := namespace.(string); isString {
+			return namespace
+		}
+	}
+	return metav1.NamespaceDefault
+}
+
+// setDefaultNamespace records namespace as clientset's default namespace until clientset
+// is garbage collected. An empty namespace records nothing, leaving the "default" fallback.
+func setDefaultNamespace(clientset *kubernetes.Clientset, namespace string) {
+	if namespace == "" {
+		return
+	}
+
+	key := weak.Make(clientset)
+	clientsetNamespaces.Store(key, namespace)
+	runtime.AddCleanup(clientset, func(key weak.Pointer[kubernetes.Clientset]) {
+		clientsetNamespaces.Delete(key)
+	}, key)
+}
+
+// inClusterNamespace returns the namespace of the pod's service account, or "" if it
+// cannot be read, e.g. when the service account token is not mounted.
+func inClusterNamespace() string {
+	contents, err := os.ReadFile(inClusterNamespaceFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(contents))
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultCordonReasonAnnotation is the node annotation CordonNode records the cordon
+// reason in when no annotation key is given.
+const DefaultCordonReasonAnnotation = "go-kube-rest-client-example/cordon-reason"
+
+// CordonReason describes who cordoned a node and why, for the audit annotation written
+// by CordonNode.
+type CordonReason struct {
+	// By identifies who or what cordoned the node, e.g. a user name or automation job.
+	By string `json:"by"`
+
+	// Reason explains why the node was cordoned, e.g. "kernel upgrade".
+	Reason string `json:"reason"`
+
+	// At is when the node was cordoned. CordonNode sets it to the current time.
+	At time.Time `json:"at"`
+}
+
+// ErrNodesForbidden is returned by ListNodes when the client may not list nodes. Nodes are
+// cluster-scoped, so a namespaced Role cannot grant this; it takes a ClusterRole bound
+// with a ClusterRoleBinding.
+var ErrNodesForbidden = errors.New(
+	"listing nodes requires list permission on nodes, granted by a ClusterRole and ClusterRoleBinding",
+)
+
+// ListNodes lists every node in the cluster, following continue tokens with ListAll so
+// large clusters are read in full, in pages of 500 nodes; if a token expires part way
+// through, the list is restarted from the beginning.
+//
+// Parameters:
+//
+//	ctx: The context used for every page request; cancelling it stops the list.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//
+// Returns:
+//
+//	All nodes in the cluster.
+//	An error wrapping ErrNodesForbidden if the client may not list nodes, or another
+//	error if a page cannot be listed.
+func ListNodes(ctx context.Context, clientset kubernetes.Interface) ([]corev1.Node, error) {
+	listNodes := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return clientset.CoreV1().Nodes().List(ctx, opts)
+	}
+
+	opts := ListAllOptions{RestartOnExpired: true, PageSize: defaultPageSize}
+	nodes, err := ListAll[corev1.Node](ctx, listNodes, opts)
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			return nil, fmt.Errorf("%w: %w", ErrNodesForbidden, err)
+		}
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	return nodes, nil
+}
+
+// LabelNodes applies a set of label changes to every node matching the given selector.
+// Each node is updated with a JSON merge patch that only touches the supplied label keys,
+// so concurrent changes to other labels or fields of the node are never clobbered and no
+// resourceVersion conflicts can occur.
+//
+// A non-nil value in nodeLabels sets (or overwrites) the label, while a nil value removes
+// the label from the node if present.
+//
+// Nodes are listed in pages of 500, each patched before the next is requested, so large
+// clusters are handled without loading the whole node list into a single response. If a
+// patch fails, LabelNodes stops and returns the number of nodes that were successfully
+// updated before the failure alongside the error.
+//
+// Parameters:
+//
+//	ctx: The context used for the list and patch requests.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	selector: The label selector identifying the nodes to update. Use labels.Everything()
+//	          to update all nodes.
+//	nodeLabels: The label changes to apply. A nil value removes the label.
+//
+// Returns:
+//
+//	The number of nodes that were patched.
+//	An error if listing the nodes or patching any of them fails, otherwise nil.
+func LabelNodes(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	selector labels.Selector,
+	nodeLabels map[string]*string,
+) (int, error) {
+	if len(nodeLabels) == 0 {
+		return 0, nil
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels": nodeLabels,
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to build node label patch: %w", err)
+	}
+
+	updated := 0
+	opts := metav1.ListOptions{LabelSelector: selector.String(), Limit: defaultPageSize}
+	for {
+		nodes, err := clientset.CoreV1().Nodes().List(ctx, opts)
+		if err != nil {
+			return updated, fmt.Errorf("failed to list nodes matching %q: %w", selector.String(), err)
+		}
+
+		for i := range nodes.Items {
+			name := nodes.Items[i].Name
+			_, err = clientset.CoreV1().Nodes().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			if err != nil {
+				return updated, fmt.Errorf("failed to patch labels on node %s: %w", name, err)
+			}
+			updated++
+		}
+
+		if nodes.Continue == "" {
+			return updated, nil
+		}
+		opts.Continue = nodes.Continue
+	}
+}
+
+// CordonNode marks a node unschedulable, like `kubectl cordon`, and records who cordoned
+// it and why as a JSON encoded CordonReason in a node annotation, so operators can audit
+// why a node was taken out of service. Pods already running on the node are not evicted.
+//
+// The change is made with a single JSON merge patch that only touches spec.unschedulable
+// and the annotation, so concurrent changes to the node are never clobbered. Cordoning an
+// already cordoned node overwrites the recorded reason.
+//
+// Parameters:
+//
+//	ctx: The context used for the patch request.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	name: The name of the node.
+//	reason: Who cordoned the node and why. Its At field is set to the current time.
+//	annotationKey: The annotation holding the reason, or "" for DefaultCordonReasonAnnotation.
+//
+// Returns:
+//
+//	An error if the patch cannot be built or fails, otherwise nil.
+func CordonNode(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	name string,
+	reason CordonReason,
+	annotationKey string,
+) error {
+	reason.At = time.Now().UTC()
+	value, err := json.Marshal(reason)
+	if err != nil {
+		return fmt.Errorf("failed to encode cordon reason for node %s: %w", name, err)
+	}
+
+	return patchNodeSchedulability(ctx, clientset, name, true, cordonAnnotationKey(annotationKey), string(value))
+}
+
+// UncordonNode marks a node schedulable again, like `kubectl uncordon`, and removes the
+// cordon reason annotation written by CordonNode, using a single JSON merge patch.
+//
+// Parameters:
+//
+//	ctx: The context used for the patch request.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	name: The name of the node.
+//	annotationKey: The annotation holding the reason, or "" for DefaultCordonReasonAnnotation.
+//
+// Returns:
+//
+//	An error if the patch cannot be built or fails, otherwise nil.
+func UncordonNode(ctx context.Context, clientset kubernetes.Interface, name, annotationKey string) error {
+	return patchNodeSchedulability(ctx, clientset, name, false, cordonAnnotationKey(annotationKey), nil)
+}
+
+// cordonAnnotationKey returns annotationKey, or DefaultCordonReasonAnnotation if it is empty.
+func cordonAnnotationKey(annotationKey string) string {
+	if annotationKey == "" {
+		return DefaultCordonReasonAnnotation
+	}
+	return annotationKey
+}
+
+// patchNodeSchedulability sets or clears spec.unschedulable and the given annotation in
+// one merge patch. A nil annotation value removes the annotation.
+func patchNodeSchedulability(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	name string,
+	unschedulable bool,
+	annotationKey string,
+	annotationValue any,
+) error {
+	// A null unschedulable removes the field, which is how kubectl uncordon leaves nodes
+	var unschedulableValue any
+	if unschedulable {
+		unschedulableValue = true
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]any{annotationKey: annotationValue},
+		},
+		"spec": map[string]any{
+			"unschedulable": unschedulableValue,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build schedulability patch for node %s: %w", name, err)
+	}
+
+	_, err = clientset.CoreV1().Nodes().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch schedulability of node %s: %w", name, err)
+	}
+	return nil
+}
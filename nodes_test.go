@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newNodeObjects returns count nodes named node-0, node-1, and so on.
+func newNodeObjects(count int) []runtime.Object {
+	objects := make([]runtime.Object, count)
+	for i := range objects {
+		objects[i] = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%d", i)}}
+	}
+	return objects
+}
+
+// newNodeList builds the NodeList page returned by pagedListReactor.
+func newNodeList(items []runtime.Object, next string) runtime.Object {
+	list := &corev1.NodeList{ListMeta: metav1.ListMeta{Continue: next}}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.(*corev1.Node))
+	}
+	return list
+}
+
+func TestListNodesPaginates(t *testing.T) {
+	objects := newNodeObjects(defaultPageSize + 1)
+	var limits []int64
+	clientset := fake.NewClientset()
+	clientset.PrependReactor("list", "nodes", pagedListReactor(objects, newNodeList, &limits))
+
+	nodes, err := ListNodes(context.Background(), clientset)
+	if err != nil {
+		t.Fatalf("ListNodes() error = %v", err)
+	}
+	if len(nodes) != len(objects) {
+		t.Errorf("ListNodes() returned %d nodes, want %d", len(nodes), len(objects))
+	}
+	if len(limits) != 2 || limits[0] != defaultPageSize || limits[1] != defaultPageSize {
+		t.Errorf("list requests used limits %v, want two pages of %d", limits, defaultPageSize)
+	}
+}
+
+func TestLabelNodesPaginates(t *testing.T) {
+	objects := newNodeObjects(defaultPageSize + 1)
+	var limits []int64
+	clientset := fake.NewClientset(objects...)
+	clientset.PrependReactor("list", "nodes", pagedListReactor(objects, newNodeList, &limits))
+
+	value := "true"
+	updated, err := LabelNodes(context.Background(), clientset, labels.Everything(), map[string]*string{"gpu": &value})
+	if err != nil {
+		t.Fatalf("LabelNodes() error = %v", err)
+	}
+	if updated != len(objects) {
+		t.Errorf("LabelNodes() updated %d nodes, want %d", updated, len(objects))
+	}
+	if len(limits) != 2 || limits[0] != defaultPageSize || limits[1] != defaultPageSize {
+		t.Errorf("list requests used limits %v, want two pages of %d", limits, defaultPageSize)
+	}
+
+	node, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-500", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if node.Labels["gpu"] != "true" {
+		t.Errorf("node-500 labels = %v, want gpu=true", node.Labels)
+	}
+}
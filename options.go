@@ -0,0 +1,231 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/rest"
+)
+
+// Option customizes how a constructor such as CreateExternalClusterKubeRestClient or
+// CreateInClusterKubeRestClient builds a client. Passing no options gives the default
+// behaviour: the connection is verified with a single attempt (see DefaultConnectRetries),
+// and client-go's defaults are used for everything else.
+type Option func(*clientOptions)
+
+// clientOptions holds the settings collected from a list of Options.
+type clientOptions struct {
+	skipConnectionCheck   bool
+	connectRetries        int
+	connectRetryBaseDelay time.Duration
+	connectRetryMaxDelay  time.Duration
+	qps                   float32
+	burst                 int
+	timeout               time.Duration
+	userAgent             string
+	disableHTTP2          bool
+	tracerProvider        trace.TracerProvider
+	transportWrappers     []func(http.RoundTripper) http.RoundTripper
+	slowRequestThreshold  time.Duration
+	slowRequestObservers  []func(*http.Request)
+	configMutators        []func(*rest.Config)
+	warningHandler        rest.WarningHandler
+	logWarnings           bool
+	logger                *slog.Logger
+}
+
+// newClientOptions returns the defaults with opts applied in order.
+func newClientOptions(opts []Option) clientOptions {
+	options := clientOptions{connectRetries: DefaultConnectRetries}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithSkipConnectionCheck returns the clientset without the server version request used
+// to verify the connection, so no network round-trip is made and the constructor succeeds
+// even if the API server is down. This suits unit tests and building many clients up
+// front. Clients built this way are reported by IsUnverified.
+func WithSkipConnectionCheck() Option {
+	return func(o *clientOptions) {
+		o.skipConnectionCheck = true
+	}
+}
+
+// WithConnectRetries sets the number of times the connection check is retried after a
+// transient failure, such as a refused connection or a timeout from an API server that
+// is still starting. Authentication and authorization failures are never retried. Zero
+// makes a single attempt, which is the default (DefaultConnectRetries).
+func WithConnectRetries(retries int) Option {
+	return func(o *clientOptions) {
+		o.connectRetries = retries
+	}
+}
+
+// WithConnectRetryDelay sets the delay before the first retry of the connection check,
+// which doubles for each further retry, and the cap on that delay. The defaults are 500ms
+// and 10s; a zero value keeps the default.
+func WithConnectRetryDelay(baseDelay, maxDelay time.Duration) Option {
+	return func(o *clientOptions) {
+		o.connectRetryBaseDelay = baseDelay
+		o.connectRetryMaxDelay = maxDelay
+	}
+}
+
+// WithQPS sets the sustained rate of requests per second the client may make before
+// client-side throttling kicks in, and the number of requests it may make in a burst
+// above that rate. Zero keeps client-go's defaults (5 and 10).
+func WithQPS(qps float32, burst int) Option {
+	return func(o *clientOptions) {
+		o.qps = qps
+		o.burst = burst
+	}
+}
+
+// WithTimeout bounds every individual API request made by the client, including the
+// connection check, so callers need not wrap each List or Get in a context with a
+// deadline. Long-running requests such as watches and log streams are cut off too, so
+// use a separate client without a timeout for those. Zero keeps client-go's default of
+// no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request, so API server audit logs can
+// tell which application made a call, e.g. "billing-sync/1.4.2". Empty keeps client-go's
+// default, which only names the binary and client-go version.
+func WithUserAgent(userAgent string) Option {
+	return func(o *clientOptions) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithDisableHTTP2 makes the client speak HTTP/1.1 only, by offering just "http/1.1"
+// during TLS protocol negotiation, for networks whose proxies or appliances mangle HTTP/2
+// and make requests hang or reset. Each concurrent request then needs its own connection,
+// and watches each hold one open. By default HTTP/2 is used when the server supports it.
+func WithDisableHTTP2() Option {
+	return func(o *clientOptions) {
+		o.disableHTTP2 = true
+	}
+}
+
+// WithWarningHandler passes the warnings the API server returns in Warning headers, such
+// as the deprecation of an API version, to handler instead of client-go's default, which
+// prints each one to stderr once. Pass rest.NoWarnings{} to discard them. When combined
+// with WithLoggedWarnings the last option given wins.
+func WithWarningHandler(handler rest.WarningHandler) Option {
+	return func(o *clientOptions) {
+		o.warningHandler = handler
+		o.logWarnings = false
+	}
+}
+
+// WithLoggedWarnings logs the warnings the API server returns in Warning headers to the
+// constructor's logger (see WithLogger) at warning level, instead of printing them to
+// stderr, so they appear alongside the rest of the application's logs.
+func WithLoggedWarnings() Option {
+	return func(o *clientOptions) {
+		o.warningHandler = nil
+		o.logWarnings = true
+	}
+}
+
+// WithConfigMutator calls mutate with the assembled rest.Config just before the client is
+// created from it, as an escape hatch for rest.Config settings no other option covers,
+// such as WarningHandler, RateLimiter, or Dial. mutate runs after the package has filled
+// in the config, including the settings of every other option, so it can override
+// anything; a mutator that changes nothing has no effect. Several mutators run in the
+// order they were given.
+func WithConfigMutator(mutate func(*rest.Config)) Option {
+	return func(o *clientOptions) {
+		o.configMutators = append(o.configMutators, mutate)
+	}
+}
+
+// WithLogger sends the constructor's log messages (connection successes, retries, and
+// warnings) to logger instead of the package-level Logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *clientOptions) {
+		o.logger = logger
+	}
+}
+
+// log returns the logger to use, falling back to the package-level Logger.
+func (opts clientOptions) log() *slog.Logger {
+	if opts.logger != nil {
+		return opts.logger
+	}
+	return Logger
+}
+
+// nextProtos returns the TLS protocols to offer, or nil for client-go's default.
+func (opts clientOptions) nextProtos() []string {
+	if opts.disableHTTP2 {
+		return []string{"http/1.1"}
+	}
+	return nil
+}
+
+// apply sets the options that map onto restConfig. Zero values leave restConfig unchanged.
+// Mutators from WithConfigMutator run last, so they see every other option's settings.
+func (opts clientOptions) apply(restConfig *rest.Config) {
+	if opts.qps != 0 {
+		restConfig.QPS = opts.qps
+	}
+	if opts.burst != 0 {
+		restConfig.Burst = opts.burst
+	}
+	if opts.timeout != 0 {
+		restConfig.Timeout = opts.timeout
+	}
+	if opts.userAgent != "" {
+		restConfig.UserAgent = opts.userAgent
+	}
+	if nextProtos := opts.nextProtos(); nextProtos != nil {
+		restConfig.NextProtos = nextProtos
+	}
+	if opts.tracerProvider != nil {
+		wrapTracing(restConfig, opts.tracerProvider)
+	}
+	for _, wrapper := range opts.transportWrappers {
+		restConfig.Wrap(wrapper)
+	}
+	if opts.slowRequestThreshold > 0 {
+		restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &slowRequestRoundTripper{
+				next:      rt,
+				threshold: opts.slowRequestThreshold,
+				logger:    opts.log(),
+				observers: opts.slowRequestObservers,
+			}
+		})
+	}
+	if opts.logWarnings {
+		restConfig.WarningHandler = warningLogger{logger: opts.log()}
+	} else if opts.warningHandler != nil {
+		restConfig.WarningHandler = opts.warningHandler
+	}
+	for _, mutate := range opts.configMutators {
+		mutate(restConfig)
+	}
+}
+
+// warningLogger is a rest.WarningHandler logging each warning.
+type warningLogger struct {
+	logger *slog.Logger
+}
+
+// HandleWarningHeader logs the warning text. Only warnings with code 299, the code
+// Kubernetes uses for all its warnings, are logged, as client-go's own handlers do.
+func (w warningLogger) HandleWarningHeader(code int, agent, text string) {
+	if code != 299 || text == "" {
+		return
+	}
+	w.logger.Warn("Kubernetes API server warning", "warning", text, "agent", agent)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+// warningServer serves every request with a Kubernetes deprecation warning.
+var warningServer = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Add("Warning", `299 - "v1beta1 Widget is deprecated"`)
+	if _, err := w.Write([]byte("ok")); err != nil {
+		panic(err)
+	}
+})
+
+// recordingWarningHandler records the text of every warning it receives.
+type recordingWarningHandler struct {
+	warnings []string
+}
+
+func (h *recordingWarningHandler) HandleWarningHeader(_ int, _ string, text string) {
+	h.warnings = append(h.warnings, text)
+}
+
+func TestWithWarningHandler(t *testing.T) {
+	handler := &recordingWarningHandler{}
+	clientset := newTestServerClientset(t, warningServer, WithWarningHandler(handler))
+
+	if err := Ping(context.Background(), clientset); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	if len(handler.warnings) != 1 || handler.warnings[0] != "v1beta1 Widget is deprecated" {
+		t.Errorf("warnings = %q, want [%q]", handler.warnings, "v1beta1 Widget is deprecated")
+	}
+}
+
+func TestWithWarningHandlerSilence(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	// The last warning option wins, so the warnings are discarded rather than logged.
+	clientset := newTestServerClientset(t, warningServer,
+		WithLogger(logger), WithLoggedWarnings(), WithWarningHandler(rest.NoWarnings{}))
+
+	if err := Ping(context.Background(), clientset); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("logged %q, want nothing", logs.String())
+	}
+}
+
+func TestWithLoggedWarnings(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	clientset := newTestServerClientset(t, warningServer, WithLogger(logger), WithLoggedWarnings())
+
+	if err := Ping(context.Background(), clientset); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	got := logs.String()
+	if !strings.Contains(got, "level=WARN") || !strings.Contains(got, `warning="v1beta1 Widget is deprecated"`) {
+		t.Errorf("logged %q, want a warning-level record with the warning text", got)
+	}
+}
+
+func TestWithDisableHTTP2NextProtos(t *testing.T) {
+	restConfig := &rest.Config{}
+	newClientOptions([]Option{WithDisableHTTP2()}).apply(restConfig)
+	if !slices.Equal(restConfig.NextProtos, []string{"http/1.1"}) {
+		t.Errorf("NextProtos = %q, want [http/1.1]", restConfig.NextProtos)
+	}
+}
+
+func TestWithDisableHTTP2(t *testing.T) {
+	tests := []struct {
+		name            string
+		appendSystemCAs bool
+		opts            []Option
+		wantProto       string
+	}{
+		{name: "default", wantProto: "HTTP/2.0"},
+		{name: "disabled", opts: []Option{WithDisableHTTP2()}, wantProto: "HTTP/1.1"},
+		{name: "custom transport default", appendSystemCAs: true, wantProto: "HTTP/2.0"},
+		{name: "custom transport disabled", appendSystemCAs: true, opts: []Option{WithDisableHTTP2()},
+			wantProto: "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var proto string
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proto = r.Proto
+				if _, err := w.Write([]byte("ok")); err != nil {
+					t.Errorf("failed to write response: %v", err)
+				}
+			}))
+			server.EnableHTTP2 = true
+			server.StartTLS()
+			t.Cleanup(server.Close)
+
+			caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+			k8sconfig := K8sConfig{
+				Name: "test",
+				Host: server.URL,
+				Config: TLSClientConfig{
+					Token:           "test-token",
+					CAData:          string(caData),
+					AppendSystemCAs: tt.appendSystemCAs,
+				},
+			}
+			opts := append(tt.opts, WithSkipConnectionCheck())
+			clientset, err := CreateExternalClusterKubeRestClient(k8sconfig, opts...)
+			if err != nil {
+				t.Fatalf("failed to create clientset: %v", err)
+			}
+
+			if err := Ping(context.Background(), clientset); err != nil {
+				t.Fatalf("Ping() error = %v", err)
+			}
+			if proto != tt.wantProto {
+				t.Errorf("request protocol = %s, want %s", proto, tt.wantProto)
+			}
+		})
+	}
+}
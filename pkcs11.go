@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+
+	"k8s.io/client-go/rest"
+)
+
+// newPKCS11Transport builds an HTTP transport that authenticates with a client certificate
+// whose private key is held on a PKCS#11 token.
+//
+// client-go's rest.TLSClientConfig only accepts key material as bytes or files, so the TLS
+// configuration is assembled by newTLSTransport instead and handed to client-go as a
+// custom transport. The certificate chain comes from the configured client certificate
+// while every signature is computed on the token.
+//
+// Parameters:
+//
+//	pkcs11Config: The PKCS#11 token and key to sign with.
+//	tlsClientConfig: The resolved TLS settings. The client certificate (and optional
+//	                 intermediates) is read from CertData or CertFile, the CA bundle
+//	                 from CAData or CAFile (system roots when neither is set).
+//	appendSystemCAs: Whether the CA bundle is trusted in addition to the system roots.
+//	proxy: The proxy to send requests through, or nil for the proxy environment variables.
+//
+// Returns:
+//
+//	An http.RoundTripper configured for mutual TLS using the token-backed key.
+//	An error if the token cannot be opened, the key cannot be found, or the certificate
+//	or CA data cannot be read or parsed.
+func newPKCS11Transport(
+	pkcs11Config *PKCS11Config,
+	tlsClientConfig rest.TLSClientConfig,
+	appendSystemCAs bool,
+	proxy func(*http.Request) (*url.URL, error),
+) (http.RoundTripper, error) {
+	signer, err := newPKCS11Signer(pkcs11Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTLSTransport(tlsClientConfig, signer, appendSystemCAs, proxy)
+}
@@ -0,0 +1,15 @@
+//go:build !pkcs11
+
+package main
+
+import (
+	"crypto"
+	"fmt"
+)
+
+// newPKCS11Signer reports that PKCS#11 support was not compiled in. Build with
+// "-tags pkcs11" to enable it; the tag is off by default because it requires cgo
+// and the vendor's PKCS#11 module at runtime.
+func newPKCS11Signer(_ *PKCS11Config) (crypto.Signer, error) {
+	return nil, fmt.Errorf("pkcs11 support is not enabled; rebuild with -tags pkcs11")
+}
@@ -0,0 +1,93 @@
+//go:build pkcs11
+
+package main
+
+import (
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/ThalesGroup/crypto11"
+)
+
+// pkcs11Contexts caches the open crypto11 context of each token, keyed by pkcs11ContextKey,
+// so building several clients for the same token logs in once instead of leaking a
+// session per client.
+var (
+	pkcs11ContextsMu sync.Mutex
+	pkcs11Contexts   = map[pkcs11ContextKey]*crypto11.Context{}
+)
+
+// pkcs11ContextKey identifies a token session. The PIN is part of the key so a
+// configuration with a different PIN does not reuse a session it could not log in to.
+type pkcs11ContextKey struct {
+	modulePath string
+	tokenLabel string
+	pin        string
+}
+
+// newPKCS11Signer opens the configured PKCS#11 module, logs in to the token, and returns
+// a crypto.Signer for the requested key pair. Signing operations are performed on the
+// token, so the private key is never exported. The token session is shared by every
+// client using the same module, token, and PIN, and stays open for the lifetime of the
+// process because the returned signer is used for every TLS handshake.
+func newPKCS11Signer(pkcs11Config *PKCS11Config) (crypto.Signer, error) {
+	if pkcs11Config.KeyID == "" && pkcs11Config.KeyLabel == "" {
+		return nil, fmt.Errorf("pkcs11 configuration requires a keyId or keyLabel")
+	}
+
+	var id, label []byte
+	if pkcs11Config.KeyID != "" {
+		var err error
+		if id, err = hex.DecodeString(pkcs11Config.KeyID); err != nil {
+			return nil, fmt.Errorf("pkcs11 keyId %q is not hex encoded: %w", pkcs11Config.KeyID, err)
+		}
+	}
+	if pkcs11Config.KeyLabel != "" {
+		label = []byte(pkcs11Config.KeyLabel)
+	}
+
+	tokenCtx, err := pkcs11Context(pkcs11Config)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := tokenCtx.FindKeyPair(id, label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find key pair on pkcs11 token %s: %w", pkcs11Config.TokenLabel, err)
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("no key pair found on pkcs11 token %s", pkcs11Config.TokenLabel)
+	}
+
+	return signer, nil
+}
+
+// pkcs11Context returns the open context of the configured token, opening and logging in
+// to it on first use.
+func pkcs11Context(pkcs11Config *PKCS11Config) (*crypto11.Context, error) {
+	key := pkcs11ContextKey{
+		modulePath: pkcs11Config.ModulePath,
+		tokenLabel: pkcs11Config.TokenLabel,
+		pin:        pkcs11Config.Pin,
+	}
+
+	pkcs11ContextsMu.Lock()
+	defer pkcs11ContextsMu.Unlock()
+
+	if tokenCtx, ok := pkcs11Contexts[key]; ok {
+		return tokenCtx, nil
+	}
+
+	tokenCtx, err := crypto11.Configure(&crypto11.Config{
+		Path:       pkcs11Config.ModulePath,
+		TokenLabel: pkcs11Config.TokenLabel,
+		Pin:        pkcs11Config.Pin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pkcs11 token %s: %w", pkcs11Config.TokenLabel, err)
+	}
+	pkcs11Contexts[key] = tokenCtx
+	return tokenCtx, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// crashLoopRestartThreshold is the number of restarts after which a container whose last
+// run crashed is reported as likely crash-looping, even if the kubelet is not currently
+// in the CrashLoopBackOff waiting state (for example while the container is running again).
+const crashLoopRestartThreshold = 3
+
+// PodRestartInfo summarizes the restart history of a single container in a pod.
+type PodRestartInfo struct {
+	// Namespace and Pod identify the pod the container belongs to.
+	Namespace string
+	Pod       string
+
+	// Container is the name of the container.
+	Container string
+
+	// InitContainer is true when the container is an init container.
+	InitContainer bool
+
+	// RestartCount is the number of times the kubelet restarted the container.
+	RestartCount int32
+
+	// LastExitCode and LastTerminationReason describe the container's previous termination,
+	// if it has one.
+	LastExitCode          int32
+	LastTerminationReason string
+
+	// LastTerminationCrashed is true when the previous termination had a non-zero exit code.
+	LastTerminationCrashed bool
+
+	// CrashLooping is true when the container is in CrashLoopBackOff, or when its last run
+	// crashed and it has been restarted at least crashLoopRestartThreshold times.
+	CrashLooping bool
+}
+
+// newPodRestartInfo builds the PodRestartInfo for one container status of pod.
+func newPodRestartInfo(pod *corev1.Pod, status *corev1.ContainerStatus, initContainer bool) PodRestartInfo {
+	info := PodRestartInfo{
+		Namespace:     pod.Namespace,
+		Pod:           pod.Name,
+		Container:     status.Name,
+		InitContainer: initContainer,
+		RestartCount:  status.RestartCount,
+	}
+
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		info.LastExitCode = terminated.ExitCode
+		info.LastTerminationReason = terminated.Reason
+		info.LastTerminationCrashed = terminated.ExitCode != 0
+	}
+
+	waitingInBackOff := status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff"
+	info.CrashLooping = waitingInBackOff ||
+		(info.LastTerminationCrashed && info.RestartCount >= crashLoopRestartThreshold)
+
+	return info
+}
+
+// GetPodRestartInfo returns the restart history of every container (including init
+// containers) in the pods matching selector in namespace ns, flagging containers that
+// are likely stuck in CrashLoopBackOff.
+//
+// Pods are listed in pages of 500 with ListAll, so large namespaces can be inspected
+// without building one enormous response; if a continue token expires part way through,
+// the list is restarted from the beginning.
+//
+// Parameters:
+//
+//	ctx: The context used for the list requests.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	ns: The namespace holding the pods, or "" for all namespaces.
+//	selector: The label selector identifying the pods to inspect.
+//
+// Returns:
+//
+//	One PodRestartInfo per container of every matching pod.
+//	An error if listing the pods fails or ctx is done, otherwise nil.
+func GetPodRestartInfo(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	ns string,
+	selector labels.Selector,
+) ([]PodRestartInfo, error) {
+	listPods := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		opts.LabelSelector = selector.String()
+		return clientset.CoreV1().Pods(ns).List(ctx, opts)
+	}
+	pods, err := ListAll[corev1.Pod](ctx, listPods, ListAllOptions{RestartOnExpired: true, PageSize: defaultPageSize})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods matching %q in namespace %s: %w", selector.String(), ns, err)
+	}
+
+	var infos []PodRestartInfo
+	for i := range pods {
+		pod := &pods[i]
+		for j := range pod.Status.InitContainerStatuses {
+			infos = append(infos, newPodRestartInfo(pod, &pod.Status.InitContainerStatuses[j], true))
+		}
+		for j := range pod.Status.ContainerStatuses {
+			infos = append(infos, newPodRestartInfo(pod, &pod.Status.ContainerStatuses[j], false))
+		}
+	}
+	return infos, nil
+}
+
+// ListAllPods lists every pod in every namespace. Large clusters are read in pages of 500
+// pods, following continue tokens with ListAll, so the result is never truncated; if a
+// token expires part way through, the list is restarted from the beginning.
+//
+// Parameters:
+//
+//	ctx: The context used for every page request; cancelling it stops the list.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//
+// Returns:
+//
+//	All pods in the cluster.
+//	An error if a page cannot be listed.
+func ListAllPods(ctx context.Context, clientset kubernetes.Interface) ([]corev1.Pod, error) {
+	listPods := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, opts)
+	}
+
+	pods, err := ListAll[corev1.Pod](ctx, listPods, ListAllOptions{RestartOnExpired: true, PageSize: defaultPageSize})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in all namespaces: %w", err)
+	}
+	return pods, nil
+}
+
+// WatchPods watches the pods in namespace and returns a continuous stream of typed add,
+// update, and delete events. It is WatchChannel over CoreV1().Pods(namespace).Watch, so
+// closed watches resume from the last seen resourceVersion, and bookmarks are handled
+// internally. After an expired resourceVersion (410 Gone) the pods are relisted and the
+// pods created, changed, or deleted while the watch was down are delivered as events.
+//
+// Parameters:
+//
+//	ctx: The context controlling the lifetime of the watch.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	namespace: The namespace to watch, or metav1.NamespaceAll for every namespace.
+//
+// Returns:
+//
+//	A channel of pod events, closed once ctx is done.
+//	An error if the first watch cannot be started.
+func WatchPods(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace string,
+) (<-chan Event[corev1.Pod], error) {
+	watchPods := func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+		return clientset.CoreV1().Pods(namespace).Watch(ctx, opts)
+	}
+
+	listPods := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return clientset.CoreV1().Pods(namespace).List(ctx, opts)
+	}
+
+	events, err := WatchChannel[corev1.Pod](ctx, watchPods, WatchChannelOptions{ListFunc: listPods})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch pods in namespace %q: %w", namespace, err)
+	}
+	return events, nil
+}
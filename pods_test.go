@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// pagedListReactor serves lists in pages of the requested Limit from objects, which the
+// fake clientset would otherwise return in one page, and records every Limit requested.
+func pagedListReactor(
+	objects []runtime.Object,
+	newList func(items []runtime.Object, next string) runtime.Object,
+	limits *[]int64,
+) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		opts := action.(k8stesting.ListActionImpl).GetListOptions()
+		*limits = append(*limits, opts.Limit)
+
+		start := 0
+		if opts.Continue != "" {
+			if _, err := fmt.Sscan(opts.Continue, &start); err != nil {
+				return true, nil, err
+			}
+		}
+		end, next := len(objects), ""
+		if opts.Limit > 0 && start+int(opts.Limit) < len(objects) {
+			end = start + int(opts.Limit)
+			next = fmt.Sprint(end)
+		}
+		return true, newList(objects[start:end], next), nil
+	}
+}
+
+// newPodObjects returns count pods labelled app=web in the default namespace, each with
+// one container.
+func newPodObjects(count int) []runtime.Object {
+	objects := make([]runtime.Object, count)
+	for i := range objects {
+		objects[i] = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("pod-%d", i),
+				Namespace: "default",
+				Labels:    map[string]string{"app": "web"},
+			},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "app"}}},
+		}
+	}
+	return objects
+}
+
+// newPodList builds the pod list page served by pagedListReactor.
+func newPodList(items []runtime.Object, next string) runtime.Object {
+	list := &corev1.PodList{ListMeta: metav1.ListMeta{Continue: next}}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.(*corev1.Pod))
+	}
+	return list
+}
+
+func TestListAllPodsPaginates(t *testing.T) {
+	objects := newPodObjects(defaultPageSize + 1)
+
+	var limits []int64
+	clientset := fake.NewClientset()
+	clientset.PrependReactor("list", "pods", pagedListReactor(objects, newPodList, &limits))
+
+	pods, err := ListAllPods(context.Background(), clientset)
+	if err != nil {
+		t.Fatalf("ListAllPods() error = %v", err)
+	}
+	if len(pods) != len(objects) {
+		t.Errorf("ListAllPods() returned %d pods, want %d", len(pods), len(objects))
+	}
+	if len(limits) != 2 || limits[0] != defaultPageSize || limits[1] != defaultPageSize {
+		t.Errorf("list requests used limits %v, want two pages of %d", limits, defaultPageSize)
+	}
+}
+
+func TestGetPodRestartInfoRestartsOnExpired(t *testing.T) {
+	objects := newPodObjects(defaultPageSize + 1)
+
+	var limits []int64
+	var selectors []string
+	expired := false
+	pages := pagedListReactor(objects, newPodList, &limits)
+	clientset := fake.NewClientset()
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		opts := action.(k8stesting.ListActionImpl).GetListOptions()
+		selectors = append(selectors, opts.LabelSelector)
+		// The first continue token expires, as when the list outlives etcd compaction
+		if opts.Continue != "" && !expired {
+			expired = true
+			return true, nil, apierrors.NewResourceExpired("continue token expired")
+		}
+		return pages(action)
+	})
+
+	selector := labels.SelectorFromSet(labels.Set{"app": "web"})
+	infos, err := GetPodRestartInfo(context.Background(), clientset, "default", selector)
+	if err != nil {
+		t.Fatalf("GetPodRestartInfo() error = %v", err)
+	}
+	if len(infos) != len(objects) {
+		t.Errorf("GetPodRestartInfo() returned %d containers, want %d", len(infos), len(objects))
+	}
+	// One page, the expired continue request, then both pages again
+	if len(selectors) != 4 {
+		t.Errorf("made %d list requests, want 4", len(selectors))
+	}
+	for _, got := range selectors {
+		if got != "app=web" {
+			t.Errorf("list request used selector %q, want app=web", got)
+		}
+	}
+	for _, limit := range limits {
+		if limit != defaultPageSize {
+			t.Errorf("list requests used limits %v, want pages of %d", limits, defaultPageSize)
+			break
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// StartPortForward forwards a port on localhost to a port of a pod and returns once the
+// local listener is ready. The forward runs in the background until ctx is cancelled or
+// the connection to the API server is lost.
+//
+// A localPort of 0 picks a free ephemeral port. The port is bound by the forwarder itself
+// and only then reported back, so unlike probing for a free port first and forwarding it
+// afterwards there is no window in which another process can take it. This makes
+// port-forwards in automated tests race-free.
+//
+// Nothing is printed: the errors client-go's forwarder reports, such as a local port that
+// cannot be listened on, are logged to Logger at warning level and included in the
+// returned error when setup fails.
+//
+// Parameters:
+//
+//	ctx: The context controlling the lifetime of the forward.
+//	restConfig: The REST configuration used to reach the API server.
+//	ns: The namespace of the pod.
+//	podName: The name of the pod to forward to.
+//	localPort: The port to listen on at localhost, or 0 to pick a free one.
+//	remotePort: The port of the pod to forward to.
+//
+// Returns:
+//
+//	The local port the forward is listening on, which is the chosen port when localPort is 0.
+//	A channel that receives the error (nil if ctx was cancelled) once the forward has stopped.
+//	An error if the forward could not be set up or stopped before becoming ready.
+func StartPortForward(
+	ctx context.Context,
+	restConfig *rest.Config,
+	ns, podName string,
+	localPort, remotePort uint16,
+) (uint16, <-chan error, error) {
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+	}
+
+	roundTripper, upgrader, err := spdy.RoundTripperFor(restConfig)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create port-forward transport: %w", err)
+	}
+
+	url := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(ns).Name(podName).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: roundTripper}, http.MethodPost, url)
+
+	stopChan := make(chan struct{})
+	readyChan := make(chan struct{})
+	ports := []string{strconv.Itoa(int(localPort)) + ":" + strconv.Itoa(int(remotePort))}
+	errOut := &portForwardErrors{pod: ns + "/" + podName}
+	forwarder, err := portforward.NewOnAddresses(
+		dialer, []string{"localhost"}, ports, stopChan, readyChan, io.Discard, errOut,
+	)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create port-forward to pod %s/%s: %w", ns, podName, err)
+	}
+
+	// stop is called both when ctx is done and on the error paths below, and closes
+	// stopChan only once. stopped is closed when the forward ends on its own, so the
+	// watcher goroutine does not outlive it when ctx is never cancelled.
+	stop := sync.OnceFunc(func() { close(stopChan) })
+	done := make(chan error, 1)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		done <- forwarder.ForwardPorts()
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-stopped:
+		}
+	}()
+
+	select {
+	case <-readyChan:
+	case err := <-done:
+		stop()
+		if err == nil {
+			err = ctx.Err()
+		}
+		if messages := errOut.String(); messages != "" {
+			return 0, nil, fmt.Errorf("failed to port-forward to pod %s/%s: %w (%s)", ns, podName, err, messages)
+		}
+		return 0, nil, fmt.Errorf("failed to port-forward to pod %s/%s: %w", ns, podName, err)
+	}
+
+	forwardedPorts, err := forwarder.GetPorts()
+	if err != nil {
+		stop()
+		return 0, nil, fmt.Errorf("failed to read forwarded ports for pod %s/%s: %w", ns, podName, err)
+	}
+
+	Logger.Info("Port-forward ready",
+		"localPort", forwardedPorts[0].Local, "pod", ns+"/"+podName, "remotePort", remotePort)
+	return forwardedPorts[0].Local, done, nil
+}
+
+// portForwardErrors receives the messages client-go's forwarder writes to its error
+// output, such as a local port that cannot be listened on. Each line is logged at warning
+// level instead of being printed to stderr, and kept so a failed setup can report it.
+type portForwardErrors struct {
+	pod      string
+	mu       sync.Mutex
+	messages []string
+}
+
+func (e *portForwardErrors) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for line := range strings.Lines(string(p)) {
+		if line = strings.TrimSpace(line); line != "" {
+			Logger.Warn("Port-forward error", "pod", e.pod, "error", line)
+			e.messages = append(e.messages, line)
+		}
+	}
+	return len(p), nil
+}
+
+// String returns the messages received so far, separated by semicolons.
+func (e *portForwardErrors) String() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return strings.Join(e.messages, "; ")
+}
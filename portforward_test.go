@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestPortForwardErrors(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := Logger
+	Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	t.Cleanup(func() { Logger = defaultLogger })
+
+	// The forwarder writes each message with fmt.Fprintf, one line at a time
+	errOut := &portForwardErrors{pod: "default/web-0"}
+	for _, port := range []int{8080, 8443} {
+		if _, err := fmt.Fprintf(errOut, "Unable to listen on port %d: address already in use\n", port); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := "Unable to listen on port 8080: address already in use; " +
+		"Unable to listen on port 8443: address already in use"
+	if got := errOut.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := strings.Count(logs.String(), `level=WARN msg="Port-forward error" pod=default/web-0`); got != 2 {
+		t.Errorf("logged %q, want 2 warnings", logs.String())
+	}
+}
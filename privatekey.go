@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/youmark/pkcs8"
+)
+
+// ErrWrongKeyPassphrase is returned when TLSClientConfig.KeyPassphrase does not decrypt the
+// client private key. Check for it with errors.Is.
+var ErrWrongKeyPassphrase = errors.New("wrong passphrase for client private key")
+
+// pemTypeEncryptedPKCS8 is the PEM block type of a PKCS#8 private key encrypted with a passphrase.
+const pemTypeEncryptedPKCS8 = "ENCRYPTED PRIVATE KEY"
+
+// decryptPrivateKey decrypts a passphrase-protected PEM encoded private key so it can be
+// used to build a TLS key pair. Both encryption formats issued by common PKI tooling are
+// supported: legacy OpenSSL encrypted PEM (a "Proc-Type: 4,ENCRYPTED" header) and
+// encrypted PKCS#8 ("ENCRYPTED PRIVATE KEY" blocks). A key that is not encrypted is
+// returned unchanged.
+//
+// Parameters:
+//
+//	keyPEM: The PEM encoded private key.
+//	passphrase: The passphrase the key is encrypted with.
+//
+// Returns:
+//
+//	The unencrypted PEM encoded private key.
+//	An error wrapping ErrWrongKeyPassphrase if the passphrase is wrong, or another error
+//	if the key cannot be parsed.
+func decryptPrivateKey(keyPEM []byte, passphrase string) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("client private key is not PEM encoded")
+	}
+
+	switch {
+	case block.Type == pemTypeEncryptedPKCS8:
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+		if err != nil {
+			if strings.Contains(err.Error(), "incorrect password") {
+				return nil, ErrWrongKeyPassphrase
+			}
+			return nil, fmt.Errorf("failed to decrypt PKCS#8 private key: %w", err)
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode decrypted private key: %w", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+
+	case x509.IsEncryptedPEMBlock(block): //nolint:staticcheck // Legacy PEM encryption is weak but still issued
+		der, err := x509.DecryptPEMBlock(block, []byte(passphrase)) //nolint:staticcheck // See above
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, ErrWrongKeyPassphrase
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt PEM private key: %w", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+
+	default:
+		return keyPEM, nil
+	}
+}
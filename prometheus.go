@@ -0,0 +1,228 @@
+//go:build prometheus
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// requestMetricsNamespace prefixes the name of every metric registered by NewRequestMetrics.
+const requestMetricsNamespace = "kube_client"
+
+// RequestMetrics holds the Prometheus collectors recording the API requests made by clients
+// built with WithRequestMetrics:
+//
+//	kube_client_requests_total{verb,resource,status}         requests made
+//	kube_client_request_errors_total{verb,resource,status}   requests that failed
+//	kube_client_request_duration_seconds{verb,resource}      request latency
+//	kube_client_slow_requests_total{verb,resource}           requests slower than the
+//	                                                         WithSlowRequestThreshold threshold
+//
+// verb is the Kubernetes verb (get, list, watch, create, update, patch, delete, or
+// deletecollection), or the lower-cased HTTP method for non-resource requests such as
+// /version. resource is the resource name, with its subresource if any (e.g. "pods/log"),
+// or the first path segment for non-resource requests. status is the HTTP status code, or
+// "error" if no response was received. A request counts as failed if no response was
+// received or its status is 400 or above.
+//
+// This file is only built with "-tags prometheus", so the Prometheus client library is not
+// linked into binaries that do not use it.
+type RequestMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	slow     *prometheus.CounterVec
+}
+
+// NewRequestMetrics creates the request collectors and registers them with registerer. It
+// may be called more than once with the same registerer, e.g. once per cluster: collectors
+// that are already registered are reused, so every client reports into the same series.
+//
+// Parameters:
+//
+//	registerer: The registry the collectors are added to, e.g. prometheus.DefaultRegisterer.
+//
+// Returns:
+//
+//	The metrics to pass to WithRequestMetrics.
+//	An error if a collector cannot be registered.
+func NewRequestMetrics(registerer prometheus.Registerer) (*RequestMetrics, error) {
+	labels := []string{"verb", "resource", "status"}
+	requests, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: requestMetricsNamespace,
+		Name:      "requests_total",
+		Help:      "Number of Kubernetes API requests, by verb, resource, and status code.",
+	}, labels))
+	if err != nil {
+		return nil, err
+	}
+
+	failures, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: requestMetricsNamespace,
+		Name:      "request_errors_total",
+		Help:      "Number of failed Kubernetes API requests, by verb, resource, and status code.",
+	}, labels))
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := registerCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: requestMetricsNamespace,
+		Name:      "request_duration_seconds",
+		Help:      "Latency of Kubernetes API requests until the response headers arrive, by verb and resource.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"verb", "resource"}))
+	if err != nil {
+		return nil, err
+	}
+
+	slow, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: requestMetricsNamespace,
+		Name:      "slow_requests_total",
+		Help:      "Number of Kubernetes API requests slower than the configured threshold, by verb and resource.",
+	}, []string{"verb", "resource"}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &RequestMetrics{requests: requests, errors: failures, duration: duration, slow: slow}, nil
+}
+
+// WithRequestMetrics records every API request made by the client into metrics. Without
+// this option no metrics are recorded.
+//
+// Like WithSlowRequestThreshold, latency is measured until the response headers arrive, so
+// long-running watches and log streams are recorded by the time taken to start them. When
+// WithSlowRequestThreshold is also given, the requests it reports are counted in
+// kube_client_slow_requests_total.
+//
+// Parameters:
+//
+//	metrics: The collectors returned by NewRequestMetrics.
+func WithRequestMetrics(metrics *RequestMetrics) Option {
+	return func(o *clientOptions) {
+		o.transportWrappers = append(o.transportWrappers, func(rt http.RoundTripper) http.RoundTripper {
+			return &metricsRoundTripper{next: rt, metrics: metrics}
+		})
+		o.slowRequestObservers = append(o.slowRequestObservers, metrics.observeSlowRequest)
+	}
+}
+
+// registerCollector registers collector with registerer, returning the collector already
+// registered in its place if there is one.
+func registerCollector[C prometheus.Collector](registerer prometheus.Registerer, collector C) (C, error) {
+	err := registerer.Register(collector)
+	if err == nil {
+		return collector, nil
+	}
+
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		if existing, ok := alreadyRegistered.ExistingCollector.(C); ok {
+			return existing, nil
+		}
+	}
+	return collector, fmt.Errorf("failed to register request metrics: %w", err)
+}
+
+// observeSlowRequest counts req as a slow request.
+func (m *RequestMetrics) observeSlowRequest(req *http.Request) {
+	verb, resource := requestVerbAndResource(req)
+	m.slow.WithLabelValues(verb, resource).Inc()
+}
+
+// metricsRoundTripper records each request into metrics.
+type metricsRoundTripper struct {
+	next    http.RoundTripper
+	metrics *RequestMetrics
+}
+
+func (rt *metricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := rt.next.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	verb, resource := requestVerbAndResource(req)
+	status := "error"
+	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+
+	rt.metrics.requests.WithLabelValues(verb, resource, status).Inc()
+	rt.metrics.duration.WithLabelValues(verb, resource).Observe(elapsed.Seconds())
+	if resp == nil || resp.StatusCode >= http.StatusBadRequest {
+		rt.metrics.errors.WithLabelValues(verb, resource, status).Inc()
+	}
+
+	return resp, err
+}
+
+// WrappedRoundTripper returns the round tripper this one delegates to.
+func (rt *metricsRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.next
+}
+
+// requestVerbAndResource derives the Kubernetes verb and resource of req from its method
+// and path, e.g. GET /api/v1/namespaces/default/pods is a list of pods. Object names and
+// namespaces are dropped, keeping the label values bounded.
+func requestVerbAndResource(req *http.Request) (string, string) {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	// Skip the API group prefix: /api/{version} or /apis/{group}/{version}.
+	var parts []string
+	switch {
+	case len(segments) > 2 && segments[0] == "api":
+		parts = segments[2:]
+	case len(segments) > 3 && segments[0] == "apis":
+		parts = segments[3:]
+	default:
+		return strings.ToLower(req.Method), segments[0]
+	}
+
+	// Skip the namespace of namespaced resources, but not the namespaces resource itself.
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+
+	resource := parts[0]
+	hasName := len(parts) > 1
+	if len(parts) > 2 {
+		resource += "/" + parts[2]
+	}
+
+	return resourceVerb(req, hasName), resource
+}
+
+// resourceVerb maps the method of a resource request to its Kubernetes verb.
+func resourceVerb(req *http.Request, hasName bool) string {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		if req.URL.Query().Get("watch") == "true" || req.URL.Query().Get("watch") == "1" {
+			return "watch"
+		}
+		if hasName {
+			return "get"
+		}
+		return "list"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		if hasName {
+			return "delete"
+		}
+		return "deletecollection"
+	default:
+		return strings.ToLower(req.Method)
+	}
+}
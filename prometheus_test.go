@@ -0,0 +1,47 @@
+//go:build prometheus
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWithRequestMetricsSlowRequests(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewRequestMetrics(registry)
+	if err != nil {
+		t.Fatalf("NewRequestMetrics() error = %v", err)
+	}
+	clientset := newTestServerClientset(t, slowServer,
+		WithRequestMetrics(metrics), WithSlowRequestThreshold(10*time.Millisecond))
+
+	// The stub server does not answer with a pod list, so the list fails after the request
+	if _, err := clientset.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{}); err == nil {
+		t.Fatal("List() error = nil, want a decode error from the stub server")
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "kube_client_slow_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["verb"] == "list" && labels["resource"] == "pods" && metric.GetCounter().GetValue() == 1 {
+				return
+			}
+		}
+	}
+	t.Error(`kube_client_slow_requests_total{verb="list",resource="pods"} is not 1`)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// Actions recorded in a ReconcileResult.
+const (
+	// ReconcileApplied means the object was created or updated with server-side apply.
+	ReconcileApplied = "applied"
+
+	// ReconcilePruned means the object was managed but no longer desired, and was deleted.
+	ReconcilePruned = "pruned"
+)
+
+// ReconcileAction records one change made by Reconcile.
+type ReconcileAction struct {
+	Action    string
+	Kind      schema.GroupVersionKind
+	Namespace string
+	Name      string
+}
+
+// ReconcileResult lists the changes made by Reconcile, in the order they were made.
+type ReconcileResult struct {
+	Actions []ReconcileAction
+}
+
+// reconcileKey identifies an object independently of the API version it was read at.
+type reconcileKey struct {
+	groupKind schema.GroupKind
+	namespace string
+	name      string
+}
+
+// reconcileTarget is a desired object resolved to the resource that serves it.
+type reconcileTarget struct {
+	object  *unstructured.Unstructured
+	mapping *meta.RESTMapping
+}
+
+// Reconcile makes the cluster match a desired set of objects: every desired object is
+// applied, then every managed object that is no longer desired is deleted (apply with
+// prune). Managed objects are those matched by pruneSelector, so each desired object must
+// carry labels matching it; otherwise it could never be pruned later and Reconcile
+// refuses to apply it.
+//
+// Objects are applied with server-side apply as fieldManager, forcing ownership of
+// conflicting fields, so the desired set always wins. All applies happen before any
+// deletion, so a failed apply never leaves the cluster with fewer objects than before.
+//
+// Only kinds present in the desired set are considered for pruning. Objects of a kind
+// that has been dropped from the desired set entirely are left in place, and an empty
+// desired set prunes nothing. Namespaced objects without a namespace are applied to the
+// default namespace. Each action is logged as it is made.
+//
+// Parameters:
+//
+//	ctx: The context used for the API requests.
+//	restConfig: The REST configuration used to reach the API server.
+//	desired: The objects that should exist.
+//	pruneSelector: The label selector identifying objects managed by this reconcile.
+//	fieldManager: The field manager name used for server-side apply.
+//
+// Returns:
+//
+//	A ReconcileResult listing every apply and prune that succeeded, even when an error
+//	is also returned.
+//	An error if a desired object is invalid or any apply, list, or delete fails.
+func Reconcile(
+	ctx context.Context,
+	restConfig *rest.Config,
+	desired []*unstructured.Unstructured,
+	pruneSelector labels.Selector,
+	fieldManager string,
+) (ReconcileResult, error) {
+	var result ReconcileResult
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return result, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	mapper, err := NewRESTMapper(ctx, restConfig, true)
+	if err != nil {
+		return result, err
+	}
+
+	targets, err := resolveReconcileTargets(mapper, desired, pruneSelector)
+	if err != nil {
+		return result, err
+	}
+
+	// Apply every desired object before pruning anything
+	desiredKeys := map[reconcileKey]struct{}{}
+	var pruneMappings []*meta.RESTMapping
+	seenGroupKinds := map[schema.GroupKind]struct{}{}
+	for _, target := range targets {
+		obj := target.object
+		gvk := obj.GroupVersionKind()
+		_, err := dynamicClient.Resource(target.mapping.Resource).Namespace(obj.GetNamespace()).
+			Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+		if err != nil {
+			return result, fmt.Errorf("failed to apply %s %s: %w", gvk.Kind, objectName(obj), err)
+		}
+		result.record(ReconcileApplied, gvk, obj)
+
+		desiredKeys[reconcileKey{gvk.GroupKind(), obj.GetNamespace(), obj.GetName()}] = struct{}{}
+		if _, seen := seenGroupKinds[gvk.GroupKind()]; !seen {
+			seenGroupKinds[gvk.GroupKind()] = struct{}{}
+			pruneMappings = append(pruneMappings, target.mapping)
+		}
+	}
+
+	// Delete managed objects of the desired kinds that are no longer desired
+	for _, mapping := range pruneMappings {
+		resource := dynamicClient.Resource(mapping.Resource)
+		managed, err := ListAll[unstructured.Unstructured](ctx,
+			func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+				opts.LabelSelector = pruneSelector.String()
+				return resource.List(ctx, opts)
+			}, ListAllOptions{RestartOnExpired: true})
+		if err != nil {
+			return result, fmt.Errorf("failed to list managed %s: %w", mapping.Resource.String(), err)
+		}
+
+		for i := range managed {
+			obj := &managed[i]
+			key := reconcileKey{mapping.GroupVersionKind.GroupKind(), obj.GetNamespace(), obj.GetName()}
+			if _, ok := desiredKeys[key]; ok || obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+
+			// The UID precondition avoids deleting an object recreated since it was listed
+			propagation := metav1.DeletePropagationBackground
+			uid := obj.GetUID()
+			err := resource.Namespace(obj.GetNamespace()).Delete(ctx, obj.GetName(), metav1.DeleteOptions{
+				PropagationPolicy: &propagation,
+				Preconditions:     &metav1.Preconditions{UID: &uid},
+			})
+			if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+				continue
+			}
+			if err != nil {
+				return result, fmt.Errorf("failed to prune %s %s: %w",
+					mapping.GroupVersionKind.Kind, objectName(obj), err)
+			}
+			result.record(ReconcilePruned, mapping.GroupVersionKind, obj)
+		}
+	}
+
+	return result, nil
+}
+
+// record appends an action to the result and logs it.
+func (r *ReconcileResult) record(action string, gvk schema.GroupVersionKind, obj *unstructured.Unstructured) {
+	r.Actions = append(r.Actions, ReconcileAction{
+		Action:    action,
+		Kind:      gvk,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	})
+	Logger.Info("Reconcile "+action, "kind", gvk.Kind, "object", objectName(obj))
+}
+
+// resolveReconcileTargets validates the desired objects and maps each to its resource,
+// defaulting the namespace of namespaced objects. It fails before anything is applied.
+func resolveReconcileTargets(
+	mapper meta.RESTMapper,
+	desired []*unstructured.Unstructured,
+	pruneSelector labels.Selector,
+) ([]reconcileTarget, error) {
+	targets := make([]reconcileTarget, 0, len(desired))
+	for _, obj := range desired {
+		gvk := obj.GroupVersionKind()
+		if gvk.Kind == "" || obj.GetName() == "" {
+			return nil, fmt.Errorf("desired object %s %q is missing a kind or name", gvk.String(), obj.GetName())
+		}
+		if !pruneSelector.Matches(labels.Set(obj.GetLabels())) {
+			return nil, fmt.Errorf("desired %s %s does not match prune selector %q",
+				gvk.Kind, objectName(obj), pruneSelector.String())
+		}
+
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find resource for %s: %w", gvk.String(), err)
+		}
+
+		obj = obj.DeepCopy()
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace(metav1.NamespaceDefault)
+			}
+		} else {
+			obj.SetNamespace("")
+		}
+		targets = append(targets, reconcileTarget{object: obj, mapping: mapping})
+	}
+
+	return targets, nil
+}
+
+// objectName formats an object's name as namespace/name, or just name if cluster-scoped.
+func objectName(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
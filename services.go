@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ServiceHasReadyEndpoints reports whether a Service currently has any ready endpoint
+// addresses, and how many distinct ready addresses it has.
+//
+// EndpointSlices are preferred. A Service may be backed by several slices, so every slice
+// labelled with the Service's name is read and addresses are de-duplicated across them
+// (an address can briefly appear in two slices while the controller rebalances). An
+// endpoint whose ready condition is unset is treated as ready, as the API specifies.
+//
+// If the cluster does not serve the EndpointSlice API, or no slices exist for the
+// Service, the legacy Endpoints object is read instead. A Service with neither is
+// reported as having no ready endpoints rather than as an error.
+//
+// Parameters:
+//
+//	ctx: The context used for the API requests.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	ns: The namespace of the Service.
+//	name: The name of the Service.
+//
+// Returns:
+//
+//	Whether at least one ready address exists.
+//	The number of distinct ready addresses.
+//	An error if reading the EndpointSlices or Endpoints fails, otherwise nil.
+func ServiceHasReadyEndpoints(ctx context.Context, clientset kubernetes.Interface, ns, name string) (bool, int, error) {
+	ready := map[string]struct{}{}
+
+	opts := metav1.ListOptions{LabelSelector: discoveryv1.LabelServiceName + "=" + name}
+	slices, err := clientset.DiscoveryV1().EndpointSlices(ns).List(ctx, opts)
+	switch {
+	case apierrors.IsNotFound(err):
+		// EndpointSlices are not served by this cluster, fall back to Endpoints below
+	case err != nil:
+		return false, 0, fmt.Errorf("failed to list endpoint slices for service %s/%s: %w", ns, name, err)
+	default:
+		for i := range slices.Items {
+			for _, endpoint := range slices.Items[i].Endpoints {
+				if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+					continue
+				}
+				for _, address := range endpoint.Addresses {
+					ready[address] = struct{}{}
+				}
+			}
+		}
+		if len(slices.Items) > 0 {
+			return len(ready) > 0, len(ready), nil
+		}
+	}
+
+	// Fall back to the legacy Endpoints object for clusters without EndpointSlices
+	endpoints, err := clientset.CoreV1().Endpoints(ns).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to get endpoints for service %s/%s: %w", ns, name, err)
+	}
+
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			ready[address.IP] = struct{}{}
+		}
+	}
+
+	return len(ready) > 0, len(ready), nil
+}
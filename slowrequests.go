@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// WithSlowRequestThreshold logs a warning to the constructor's logger (see WithLogger) for
+// every API request that takes longer than threshold, including the method, path, status,
+// and duration. This surfaces a misbehaving API server or network without enabling
+// verbose client-go logging. When WithRequestMetrics is also given, slow requests are
+// counted in kube_client_slow_requests_total as well. A threshold of zero or less, the
+// default, reports nothing.
+//
+// The duration is measured until the response headers arrive, so long-running watches and
+// log streams are only reported if the server is slow to start them.
+//
+// Parameters:
+//
+//	threshold: The duration above which a request is reported.
+func WithSlowRequestThreshold(threshold time.Duration) Option {
+	return func(o *clientOptions) {
+		o.slowRequestThreshold = threshold
+	}
+}
+
+// slowRequestRoundTripper reports requests slower than threshold to logger and to every
+// observer.
+type slowRequestRoundTripper struct {
+	next      http.RoundTripper
+	threshold time.Duration
+	logger    *slog.Logger
+	observers []func(*http.Request)
+}
+
+func (rt *slowRequestRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := rt.next.RoundTrip(req)
+	elapsed := time.Since(start)
+	if elapsed <= rt.threshold {
+		return resp, err
+	}
+
+	status := "error"
+	if resp != nil {
+		status = resp.Status
+	}
+	rt.logger.Warn("Slow Kubernetes API request",
+		"method", req.Method, "path", req.URL.Path, "status", status,
+		"duration", elapsed.Round(time.Millisecond), "threshold", rt.threshold)
+	for _, observe := range rt.observers {
+		observe(req)
+	}
+
+	return resp, err
+}
+
+// WrappedRoundTripper returns the round tripper this one delegates to.
+func (rt *slowRequestRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.next
+}
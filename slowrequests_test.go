@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowServer answers every request after a delay of 50ms.
+var slowServer = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	time.Sleep(50 * time.Millisecond)
+	if _, err := w.Write([]byte("ok")); err != nil {
+		panic(err)
+	}
+})
+
+func TestWithSlowRequestThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		wantLog   bool
+	}{
+		{name: "slower than threshold", threshold: 10 * time.Millisecond, wantLog: true},
+		{name: "faster than threshold", threshold: time.Minute},
+		{name: "disabled", threshold: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+			clientset := newTestServerClientset(t, slowServer,
+				WithLogger(logger), WithSlowRequestThreshold(tt.threshold))
+
+			if err := Ping(context.Background(), clientset); err != nil {
+				t.Fatalf("Ping() error = %v", err)
+			}
+			if got := strings.Contains(logs.String(), `msg="Slow Kubernetes API request"`); got != tt.wantLog {
+				t.Errorf("logged %q, want slow request logged %v", logs.String(), tt.wantLog)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+// ConnectionStats holds in-memory counters for the API requests made by clients, for basic
+// observability without a metrics dependency. Create one with NewConnectionStats and pass
+// it to the constructors with WithConnectionStats; clients built without it pay no
+// overhead.
+type ConnectionStats struct {
+	requests   atomic.Int64
+	errors     atomic.Int64
+	retries    atomic.Int64
+	reconnects atomic.Int64
+	dialed     atomic.Bool
+}
+
+// ConnectionStatsSnapshot is a point-in-time copy of ConnectionStats.
+type ConnectionStatsSnapshot struct {
+	// Requests is the number of HTTP requests sent, including retries.
+	Requests int64 `json:"requests"`
+
+	// Errors is the number of requests that failed at the transport level (connection
+	// refused, TLS failure, timeout) or were answered with a 5xx status.
+	Errors int64 `json:"errors"`
+
+	// Retries is the number of responses that client-go retries: 429 Too Many Requests
+	// and 5xx responses carrying a Retry-After header.
+	Retries int64 `json:"retries"`
+
+	// Reconnects is the number of new connections opened after the first one, which
+	// indicates the previous connection was closed or is saturated.
+	Reconnects int64 `json:"reconnects"`
+}
+
+// NewConnectionStats returns zeroed counters to pass to WithConnectionStats.
+func NewConnectionStats() *ConnectionStats {
+	return &ConnectionStats{}
+}
+
+// WithConnectionStats counts every API request made by the client into stats. Several
+// clients may share the same stats, which then add up their requests. Without this
+// option nothing is counted.
+//
+// Parameters:
+//
+//	stats: The counters returned by NewConnectionStats.
+func WithConnectionStats(stats *ConnectionStats) Option {
+	return func(o *clientOptions) {
+		o.transportWrappers = append(o.transportWrappers, func(rt http.RoundTripper) http.RoundTripper {
+			return &statsRoundTripper{next: rt, stats: stats}
+		})
+	}
+}
+
+// Stats returns the current values of the counters.
+func (s *ConnectionStats) Stats() ConnectionStatsSnapshot {
+	return ConnectionStatsSnapshot{
+		Requests:   s.requests.Load(),
+		Errors:     s.errors.Load(),
+		Retries:    s.retries.Load(),
+		Reconnects: s.reconnects.Load(),
+	}
+}
+
+// Publish registers the counters with expvar under name, so they are served as JSON on
+// /debug/vars by any HTTP server using http.DefaultServeMux. Like expvar.Publish, it
+// panics if name is already registered, so call it once per name.
+//
+// Parameters:
+//
+//	name: The expvar variable name, e.g. "kubernetes_client".
+func (s *ConnectionStats) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return s.Stats()
+	}))
+}
+
+// statsRoundTripper updates ConnectionStats for every request.
+type statsRoundTripper struct {
+	next  http.RoundTripper
+	stats *ConnectionStats
+}
+
+func (rt *statsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.stats.requests.Add(1)
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused && rt.stats.dialed.Swap(true) {
+				rt.stats.reconnects.Add(1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := rt.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		rt.stats.errors.Add(1)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		rt.stats.retries.Add(1)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		rt.stats.errors.Add(1)
+		if resp.Header.Get("Retry-After") != "" {
+			rt.stats.retries.Add(1)
+		}
+	}
+
+	return resp, err
+}
+
+// WrappedRoundTripper returns the round tripper this one delegates to.
+func (rt *statsRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.next
+}
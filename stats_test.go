@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestWithConnectionStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   ConnectionStatsSnapshot
+	}{
+		{name: "ok", status: http.StatusOK, want: ConnectionStatsSnapshot{Requests: 2}},
+		{name: "server error", status: http.StatusInternalServerError,
+			want: ConnectionStatsSnapshot{Requests: 2, Errors: 2}},
+		{name: "too many requests", status: http.StatusTooManyRequests,
+			want: ConnectionStatsSnapshot{Requests: 2, Retries: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := NewConnectionStats()
+			clientset := newTestServerClientset(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				if _, err := w.Write([]byte("ok")); err != nil {
+					panic(err)
+				}
+			}), WithConnectionStats(stats))
+
+			for range 2 {
+				if err := Ping(context.Background(), clientset); (err != nil) != (tt.status != http.StatusOK) {
+					t.Fatalf("Ping() error = %v", err)
+				}
+			}
+			if got := stats.Stats(); got != tt.want {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
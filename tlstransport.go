@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/rest"
+)
+
+// newTLSTransport builds an HTTP transport from resolved TLS settings, for the cases
+// rest.TLSClientConfig cannot express: a private key held by a crypto.Signer rather than
+// as bytes, and a CA bundle trusted in addition to the system roots rather than instead of
+// them. client-go then uses it as a custom transport, adding its authentication and
+// rate-limiting layers on top.
+//
+// Parameters:
+//
+//	tlsClientConfig: The resolved TLS settings. The client certificate (and optional
+//	                 intermediates) is read from CertData or CertFile, its key from KeyData
+//	                 or KeyFile unless signer is set, and the CA bundle from CAData or
+//	                 CAFile (system roots when neither is set). NextProtos, if set, are
+//	                 the protocols offered during TLS negotiation.
+//	signer: The private key of the client certificate, or nil to use the configured key.
+//	appendSystemCAs: Whether the CA bundle is trusted in addition to the system roots.
+//	proxy: The proxy to send requests through, or nil for the proxy environment variables.
+//
+// Returns:
+//
+//	An http.RoundTripper configured with the TLS settings.
+//	An error if the certificate, key, or CA data cannot be read or parsed, or the system
+//	roots cannot be loaded.
+func newTLSTransport(
+	tlsClientConfig rest.TLSClientConfig,
+	signer crypto.Signer,
+	appendSystemCAs bool,
+	proxy func(*http.Request) (*url.URL, error),
+) (http.RoundTripper, error) {
+	certData, err := dataFromSliceOrFile(tlsClientConfig.CertData, tlsClientConfig.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	caData, err := dataFromSliceOrFile(tlsClientConfig.CAData, tlsClientConfig.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: tlsClientConfig.Insecure,
+		ServerName:         tlsClientConfig.ServerName,
+		NextProtos:         tlsClientConfig.NextProtos,
+	}
+
+	switch {
+	case signer != nil:
+		chain := pemCertificates(certData)
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("no PEM certificate found in certificate data")
+		}
+		tlsConfig.Certificates = []tls.Certificate{{Certificate: chain, PrivateKey: signer}}
+	case len(certData) > 0:
+		keyData, err := dataFromSliceOrFile(tlsClientConfig.KeyData, tlsClientConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key: %w", err)
+		}
+		cert, err := tls.X509KeyPair(certData, keyData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(caData) > 0 {
+		pool := x509.NewCertPool()
+		if appendSystemCAs {
+			if pool, err = x509.SystemCertPool(); err != nil {
+				return nil, fmt.Errorf("failed to load system CA certificates: %w", err)
+			}
+		}
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no PEM certificate found in CA data")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+
+	return utilnet.SetTransportDefaults(&http.Transport{
+		Proxy:           proxy,
+		TLSClientConfig: tlsConfig,
+	}), nil
+}
+
+// pemCertificates returns the DER bytes of every certificate in data, in order.
+func pemCertificates(data []byte) [][]byte {
+	var chain [][]byte
+	for remaining := data; ; {
+		var block *pem.Block
+		block, remaining = pem.Decode(remaining)
+		if block == nil {
+			return chain
+		}
+		if block.Type == "CERTIFICATE" {
+			chain = append(chain, block.Bytes)
+		}
+	}
+}
+
+// dataFromSliceOrFile returns data if it is non-empty, otherwise the contents of file.
+// Both being empty yields nil data and no error.
+func dataFromSliceOrFile(data []byte, file string) ([]byte, error) {
+	if len(data) > 0 || file == "" {
+		return data, nil
+	}
+
+	return os.ReadFile(file)
+}
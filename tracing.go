@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/rest"
+)
+
+// WithTracing makes every API request made by the client emit an OpenTelemetry client
+// span, created with tp, carrying the request method, URL, and response status, so
+// Kubernetes API calls appear in distributed traces alongside the rest of the stack. The
+// trace context is propagated to the API server using the global propagator.
+//
+// The tracing transport wraps client-go's own transport, including its TLS and
+// authentication layers, rather than replacing it. Without this option no spans are
+// created.
+//
+// Parameters:
+//
+//	tp: The tracer provider the spans are created with, e.g. otel.GetTracerProvider().
+func WithTracing(tp trace.TracerProvider) Option {
+	return func(o *clientOptions) {
+		o.tracerProvider = tp
+	}
+}
+
+// wrapTracing wraps restConfig's transport in a client span per request.
+func wrapTracing(restConfig *rest.Config, tp trace.TracerProvider) {
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &tracingRoundTripper{
+			next:   rt,
+			traced: otelhttp.NewTransport(rt, otelhttp.WithTracerProvider(tp)),
+		}
+	})
+}
+
+// tracingRoundTripper sends requests through an otelhttp transport. It exists so the
+// wrapped transport stays reachable through WrappedRoundTripper, which otelhttp does not
+// implement.
+type tracingRoundTripper struct {
+	next   http.RoundTripper
+	traced http.RoundTripper
+}
+
+func (rt *tracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt.traced.RoundTrip(req)
+}
+
+// WrappedRoundTripper returns the round tripper this one delegates to.
+func (rt *tracingRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.next
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+	"weak"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes"
+)
+
+// unverifiedClientsets records the clientsets returned without a successful connection
+// check. Keys are weak so that recording a clientset does not keep it alive; the entry is
+// removed once the clientset is garbage collected.
+var unverifiedClientsets sync.Map // map[weak.Pointer[kubernetes.Clientset]]struct{}
+
+// IsUnverified reports whether clientset was returned by a constructor without its
+// connection being verified, either because the cluster refused the discovery request
+// used for the check or because WithSkipConnectionCheck was passed. Such a client
+// may still be fully usable for the resources it is authorized for, but its credentials
+// and the server's reachability have not been confirmed.
+//
+// Parameters:
+//
+//	clientset: A clientset returned by CreateExternalClusterKubeRestClient or
+//	           CreateInClusterKubeRestClient.
+//
+// Returns:
+//
+//	true if the connection check was skipped, false otherwise.
+func IsUnverified(clientset *kubernetes.Clientset) bool {
+	_, ok := unverifiedClientsets.Load(weak.Make(clientset))
+	return ok
+}
+
+// markUnverified records clientset as unverified until it is garbage collected.
+func markUnverified(clientset *kubernetes.Clientset) {
+	key := weak.Make(clientset)
+	unverifiedClientsets.Store(key, struct{}{})
+	runtime.AddCleanup(clientset, func(key weak.Pointer[kubernetes.Clientset]) {
+		unverifiedClientsets.Delete(key)
+	}, key)
+}
+
+// verifyServerVersion checks that clientset can reach the API server by reading its
+// version, bounded by ctx. Locked-down clusters may deny or hide the discovery endpoints
+// while still allowing real work, so a 403 or 404 is not treated as a failure: a warning
+// is logged to logger, the clientset is marked unverified (see IsUnverified), and a nil
+// version is returned. Any other error, including 401 Unauthorized, is returned.
+func verifyServerVersion(
+	ctx context.Context,
+	clientset *kubernetes.Clientset,
+	clusterName string,
+	logger *slog.Logger,
+) (*version.Info, error) {
+	serverVersion, err := getServerVersion(ctx, clientset.Discovery())
+	if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+		logger.Warn("Discovery is not available, returning an unverified client",
+			"cluster", clusterName, "error", err)
+		markUnverified(clientset)
+		return nil, nil
+	}
+
+	return serverVersion, err
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+)
+
+// UnsupportedVersionError is returned by CheckVersionSupported when the API server's
+// version is outside the supported range. Check for it with errors.As.
+type UnsupportedVersionError struct {
+	// Version is the GitVersion reported by the API server, e.g. "v1.28.3-gke.1286000".
+	Version string
+
+	// MinVersion and MaxVersion are the bounds the version was checked against. An empty
+	// bound is unbounded.
+	MinVersion string
+	MaxVersion string
+}
+
+// Error implements the error interface.
+func (e *UnsupportedVersionError) Error() string {
+	switch {
+	case e.MinVersion != "" && e.MaxVersion != "":
+		return fmt.Sprintf("Kubernetes version %s is not supported, supported versions are %s to %s",
+			e.Version, e.MinVersion, e.MaxVersion)
+	case e.MinVersion != "":
+		return fmt.Sprintf("Kubernetes version %s is not supported, minimum supported version is %s",
+			e.Version, e.MinVersion)
+	default:
+		return fmt.Sprintf("Kubernetes version %s is not supported, maximum supported version is %s",
+			e.Version, e.MaxVersion)
+	}
+}
+
+// ServerVersion is the version reported by an API server, with the major and minor
+// versions parsed so features can be gated without string handling, e.g.
+//
+//	if v.AtLeast(1, 25) { ... }
+type ServerVersion struct {
+	// Info is the version information as reported by the server.
+	Info *version.Info
+
+	// Major and Minor are the numeric major and minor versions, parsed from GitVersion
+	// (vendor suffixes such as "+k3s1" ignored), or zero if it cannot be parsed.
+	Major uint
+	Minor uint
+}
+
+// AtLeast reports whether the server version is major.minor or newer.
+func (v *ServerVersion) AtLeast(major, minor uint) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+// GetServerVersion reads the API server's version, bounded by ctx, with the major and
+// minor versions parsed. The constructors' ...WithVersion variants return the version
+// read by their connection check instead, saving the request.
+//
+// Parameters:
+//
+//	ctx: The context used for the version request.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//
+// Returns:
+//
+//	The server version.
+//	An error if the request fails.
+func GetServerVersion(ctx context.Context, clientset kubernetes.Interface) (*ServerVersion, error) {
+	info, err := getServerVersion(ctx, clientset.Discovery())
+	if err != nil {
+		return nil, err
+	}
+	return newServerVersion(info), nil
+}
+
+// newServerVersion parses info into a ServerVersion, returning nil for nil info.
+func newServerVersion(info *version.Info) *ServerVersion {
+	if info == nil {
+		return nil
+	}
+
+	serverVersion := &ServerVersion{Info: info}
+	if parsed, err := utilversion.ParseGeneric(info.GitVersion); err == nil {
+		serverVersion.Major = parsed.Major()
+		serverVersion.Minor = parsed.Minor()
+	}
+	return serverVersion
+}
+
+// CheckVersionSupported reads the API server's version and checks that it lies within
+// [minVersion, maxVersion], so a tool can refuse to operate on a cluster it does not
+// support instead of failing in obscure ways later.
+//
+// Versions are compared numerically by their major, minor, and patch components. Vendor
+// suffixes such as "v1.28.3+k3s1" or "v1.28.3-gke.1286000" are ignored, so a vendor build
+// counts as the upstream release it is based on. Either bound may omit the patch version:
+// a maxVersion of "1.30" admits every 1.30.x release. An empty bound is unbounded.
+//
+// Parameters:
+//
+//	ctx: The context used for the version request.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	minVersion: The oldest supported version, e.g. "1.27", or "" for no lower bound.
+//	maxVersion: The newest supported version, e.g. "1.30", or "" for no upper bound.
+//
+// Returns:
+//
+//	nil if the server version is within the range.
+//	An *UnsupportedVersionError if it is outside the range.
+//	Another error if a bound or the server version cannot be parsed, or the request fails.
+func CheckVersionSupported(ctx context.Context, clientset kubernetes.Interface, minVersion, maxVersion string) error {
+	lower, err := parseVersionBound(minVersion)
+	if err != nil {
+		return err
+	}
+	upper, err := parseVersionBound(maxVersion)
+	if err != nil {
+		return err
+	}
+
+	info, err := getServerVersion(ctx, clientset.Discovery())
+	if err != nil {
+		return err
+	}
+
+	serverVersion, err := utilversion.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return fmt.Errorf("failed to parse server version %q: %w", info.GitVersion, err)
+	}
+
+	if (lower != nil && serverVersion.LessThan(lower)) ||
+		(upper != nil && truncateVersion(serverVersion, upper).GreaterThan(upper)) {
+		return &UnsupportedVersionError{Version: info.GitVersion, MinVersion: minVersion, MaxVersion: maxVersion}
+	}
+
+	return nil
+}
+
+// getServerVersion reads the API server's version like discovery's ServerVersion, but
+// bounded by ctx, so an unreachable server fails once ctx is done instead of hanging.
+// A discovery client without a REST client, such as the fake one in
+// k8s.io/client-go/discovery/fake, is asked through ServerVersion instead.
+func getServerVersion(ctx context.Context, client discovery.DiscoveryInterface) (*version.Info, error) {
+	restClient := client.RESTClient()
+	if restClient == nil {
+		info, err := client.ServerVersion()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get server version: %w", err)
+		}
+		return info, nil
+	}
+
+	body, err := restClient.Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server version: %w", err)
+	}
+
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode server version: %w", err)
+	}
+	return &info, nil
+}
+
+// parseVersionBound parses a version range bound, returning nil for an empty bound.
+func parseVersionBound(bound string) (*utilversion.Version, error) {
+	if bound == "" {
+		return nil, nil
+	}
+
+	parsed, err := utilversion.ParseGeneric(bound)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version bound %q: %w", bound, err)
+	}
+	return parsed, nil
+}
+
+// truncateVersion drops the patch version from v if bound has none, so that v compares
+// equal to a "major.minor" bound for every patch release.
+func truncateVersion(v, bound *utilversion.Version) *utilversion.Version {
+	if len(bound.Components()) > 2 {
+		return v
+	}
+	return utilversion.MajorMinor(v.Major(), v.Minor())
+}
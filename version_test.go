@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeClientsetWithVersion returns a fake clientset whose discovery client reports gitVersion.
+func newFakeClientsetWithVersion(gitVersion string) *fake.Clientset {
+	clientset := fake.NewClientset()
+	discoveryClient, ok := clientset.Discovery().(*fakediscovery.FakeDiscovery)
+	if !ok {
+		panic("fake clientset has no fake discovery client")
+	}
+	discoveryClient.FakedServerVersion = &version.Info{GitVersion: gitVersion}
+	return clientset
+}
+
+func TestGetServerVersionFakeClientset(t *testing.T) {
+	serverVersion, err := GetServerVersion(context.Background(), newFakeClientsetWithVersion("v1.30.2+k3s1"))
+	if err != nil {
+		t.Fatalf("GetServerVersion() error = %v", err)
+	}
+	if serverVersion.Major != 1 || serverVersion.Minor != 30 {
+		t.Errorf("GetServerVersion() = %d.%d, want 1.30", serverVersion.Major, serverVersion.Minor)
+	}
+}
+
+func TestGetServerVersionFakeClientsetError(t *testing.T) {
+	clientset := fake.NewClientset()
+	wantErr := errors.New("connection refused")
+	clientset.PrependReactor("get", "version", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, wantErr
+	})
+
+	if _, err := GetServerVersion(context.Background(), clientset); !errors.Is(err, wantErr) {
+		t.Errorf("GetServerVersion() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestCheckVersionSupportedFakeClientset(t *testing.T) {
+	tests := []struct {
+		name       string
+		gitVersion string
+		minVersion string
+		maxVersion string
+		wantErr    bool
+	}{
+		{name: "within range", gitVersion: "v1.29.4", minVersion: "1.27", maxVersion: "1.30"},
+		{name: "patch release of max", gitVersion: "v1.30.9", maxVersion: "1.30"},
+		{name: "too old", gitVersion: "v1.26.0", minVersion: "1.27", wantErr: true},
+		{name: "too new", gitVersion: "v1.31.0-gke.1", maxVersion: "1.30", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := newFakeClientsetWithVersion(tt.gitVersion)
+			err := CheckVersionSupported(context.Background(), clientset, tt.minVersion, tt.maxVersion)
+
+			var unsupported *UnsupportedVersionError
+			if got := errors.As(err, &unsupported); got != tt.wantErr {
+				t.Errorf("CheckVersionSupported() error = %v, want unsupported %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// WatchFunc starts a watch. It is typically a thin closure over a typed or dynamic
+// client's Watch method, e.g.
+//
+//	func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+//		return clientset.CoreV1().Pods("default").Watch(ctx, opts)
+//	}
+type WatchFunc func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+
+// Event is a typed watch event delivered by WatchChannel. Type is watch.Added,
+// watch.Modified, or watch.Deleted.
+type Event[T any] struct {
+	Type   watch.EventType
+	Object *T
+}
+
+// WatchChannelOptions controls the channel returned by WatchChannel.
+type WatchChannelOptions struct {
+	// BufferSize is the capacity of the returned channel. While the buffer has room the
+	// watch keeps reading from the API server even if the consumer is momentarily busy;
+	// once it is full the watch blocks until the consumer catches up. Zero means unbuffered.
+	BufferSize int
+
+	// ListFunc lists the objects being watched, e.g. the matching closure over a typed
+	// client's List method. When the watch's resourceVersion expires (410 Gone), the
+	// current objects are listed and compared with those already delivered: new objects
+	// are sent as Added, changed ones as Modified, and objects that disappeared while the
+	// watch was down as Deleted, before the watch resumes from the list's resourceVersion.
+	// This keeps the last seen version of every object in memory, like an informer.
+	//
+	// When nil, an expired watch restarts from the current state instead, re-delivering
+	// every existing object as an Added event, and deletions during the gap are missed.
+	ListFunc ListFunc
+}
+
+// WatchChannel starts a watch with watchFunc and returns a channel of typed add, update,
+// and delete events, as an alternative to handling client-go's watch.Interface directly.
+//
+// Reconnection is handled internally: when the server closes the watch it is resumed from
+// the last seen resourceVersion. If that resourceVersion has expired, the objects are
+// relisted with opts.ListFunc and the differences delivered as events, so the stream stays
+// continuous (see WatchChannelOptions.ListFunc). Watches that fail to restart are retried
+// until ctx is done. Bookmark and error events are consumed internally. The channel is
+// closed when ctx is cancelled.
+//
+// Restarts back off exponentially, like the constructors' connection check: a watch that
+// fails to start, or ends without delivering any event, is retried after 500ms, doubling
+// for each consecutive failure up to 10s. A watch that delivers events resets the delay.
+//
+// T is the object type, e.g. corev1.Pod for a pod watch or unstructured.Unstructured for
+// a dynamic watch.
+//
+// Parameters:
+//
+//	ctx: The context controlling the lifetime of the watch.
+//	watchFunc: The function starting a single watch.
+//	opts: Controls buffering of the returned channel and relisting after expiry.
+//
+// Returns:
+//
+//	A channel of events, closed once ctx is done.
+//	An error if the first watch cannot be started.
+func WatchChannel[T any](ctx context.Context, watchFunc WatchFunc, opts WatchChannelOptions) (<-chan Event[T], error) {
+	w := &channelWatch[T]{
+		watchFunc: watchFunc,
+		listFunc:  opts.ListFunc,
+		listOpts:  metav1.ListOptions{AllowWatchBookmarks: true},
+		known:     map[string]*T{},
+	}
+	watcher, err := watchFunc(ctx, w.listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start watch: %w", err)
+	}
+
+	events := make(chan Event[T], opts.BufferSize)
+	w.events = events
+	go func() {
+		defer close(events)
+		for {
+			expired, delivered := w.forward(ctx, watcher)
+			watcher.Stop()
+			if delivered {
+				w.delay = 0
+			} else {
+				w.wait(ctx, w.nextDelay())
+			}
+
+			watcher = w.restart(ctx, expired)
+			if watcher == nil {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// channelWatch is the state of the watch behind a WatchChannel.
+type channelWatch[T any] struct {
+	watchFunc WatchFunc
+	listFunc  ListFunc
+	events    chan<- Event[T]
+
+	// listOpts starts the next watch; its ResourceVersion is the last one seen.
+	listOpts metav1.ListOptions
+
+	// known holds the last delivered version of every object by namespace/name, for
+	// relisting. It is only maintained when listFunc is set.
+	known map[string]*T
+
+	// delay is the last wait between two restarts, or zero after a healthy watch.
+	delay time.Duration
+}
+
+// restart starts a new watch, retrying until it succeeds or ctx is done, in which case it
+// returns nil. When the previous watch expired, or the new one cannot start because its
+// resourceVersion expired, the objects are relisted first, or the watch starts afresh
+// without a listFunc.
+func (w *channelWatch[T]) restart(ctx context.Context, expired bool) watch.Interface {
+	for ctx.Err() == nil {
+		if expired {
+			if err := w.relist(ctx); err != nil {
+				delay := w.nextDelay()
+				Logger.Warn("Failed to relist watched objects, retrying", "retryIn", delay, "error", err)
+				w.wait(ctx, delay)
+				continue
+			}
+			expired = false
+		}
+
+		watcher, err := w.watchFunc(ctx, w.listOpts)
+		if err == nil {
+			return watcher
+		}
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			expired = true
+			continue
+		}
+
+		delay := w.nextDelay()
+		Logger.Warn("Failed to restart watch, retrying", "retryIn", delay, "error", err)
+		w.wait(ctx, delay)
+	}
+
+	return nil
+}
+
+// nextDelay returns how long to wait before the next restart, doubling the previous delay
+// from defaultConnectRetryBaseDelay up to defaultConnectRetryMaxDelay.
+func (w *channelWatch[T]) nextDelay() time.Duration {
+	w.delay = min(max(2*w.delay, defaultConnectRetryBaseDelay), defaultConnectRetryMaxDelay)
+	return w.delay
+}
+
+// wait sleeps for delay or until ctx is done.
+func (w *channelWatch[T]) wait(ctx context.Context, delay time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(delay):
+	}
+}
+
+// relist replaces an expired resourceVersion. Without a listFunc it is cleared, so the next
+// watch starts from the current state. Otherwise the objects are listed, the differences
+// from the known objects sent as events, and the list's resourceVersion used.
+func (w *channelWatch[T]) relist(ctx context.Context) error {
+	if w.listFunc == nil {
+		w.listOpts.ResourceVersion = ""
+		return nil
+	}
+
+	listOpts := ListAllOptions{RestartOnExpired: true, PageSize: defaultPageSize}
+	items, resourceVersion, err := listAllWithVersion[T](ctx, w.listFunc, listOpts)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]*T, len(items))
+	for i := range items {
+		object := &items[i]
+		key := objectKey(object)
+		current[key] = object
+
+		previous, seen := w.known[key]
+		switch {
+		case !seen:
+			err = w.send(ctx, watch.Added, object)
+		case objectResourceVersion(previous) != objectResourceVersion(object):
+			err = w.send(ctx, watch.Modified, object)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for key, object := range w.known {
+		if _, exists := current[key]; !exists {
+			if err := w.send(ctx, watch.Deleted, object); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.known = current
+	w.listOpts.ResourceVersion = resourceVersion
+	return nil
+}
+
+// send delivers an event, recording the object as known, unless ctx is done first.
+func (w *channelWatch[T]) send(ctx context.Context, eventType watch.EventType, object *T) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case w.events <- Event[T]{Type: eventType, Object: object}:
+	}
+
+	if w.listFunc != nil {
+		if eventType == watch.Deleted {
+			delete(w.known, objectKey(object))
+		} else {
+			w.known[objectKey(object)] = object
+		}
+	}
+	return nil
+}
+
+// forward sends events from watcher to the channel, tracking the last seen
+// resourceVersion, until the watch ends or ctx is done. It reports whether the watch
+// ended because its resourceVersion expired, and whether it delivered any object or
+// bookmark event.
+func (w *channelWatch[T]) forward(ctx context.Context, watcher watch.Interface) (bool, bool) {
+	delivered := false
+	for {
+		var event watch.Event
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return false, delivered
+		case event, ok = <-watcher.ResultChan():
+			if !ok {
+				return false, delivered
+			}
+		}
+
+		switch event.Type {
+		case watch.Error:
+			err := apierrors.FromObject(event.Object)
+			return apierrors.IsResourceExpired(err) || apierrors.IsGone(err), delivered
+		case watch.Bookmark:
+			if accessor, err := meta.Accessor(event.Object); err == nil {
+				w.listOpts.ResourceVersion = accessor.GetResourceVersion()
+			}
+			delivered = true
+			continue
+		case watch.Added, watch.Modified, watch.Deleted:
+		}
+
+		object, isT := any(event.Object).(*T)
+		if !isT {
+			Logger.Warn("Skipping watch event with unexpected object type", "type", fmt.Sprintf("%T", event.Object))
+			continue
+		}
+		if err := w.send(ctx, event.Type, object); err != nil {
+			return false, delivered
+		}
+		w.listOpts.ResourceVersion = objectResourceVersion(object)
+		delivered = true
+	}
+}
+
+// objectKey returns the namespace/name key of object, or its name if cluster-scoped.
+func objectKey(object any) string {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return ""
+	}
+	if accessor.GetNamespace() == "" {
+		return accessor.GetName()
+	}
+	return accessor.GetNamespace() + "/" + accessor.GetName()
+}
+
+// objectResourceVersion returns the resourceVersion of object, or "" if it has none.
+func objectResourceVersion(object any) string {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return ""
+	}
+	return accessor.GetResourceVersion()
+}
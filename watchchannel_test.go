@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// newWatchPod returns a pod named name at resourceVersion.
+func newWatchPod(name, resourceVersion string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:            name,
+		Namespace:       "default",
+		ResourceVersion: resourceVersion,
+	}}
+}
+
+// expiredStatus is the error event sent when a watch's resourceVersion is too old.
+var expiredStatus = &metav1.Status{
+	Status: metav1.StatusFailure,
+	Code:   http.StatusGone,
+	Reason: metav1.StatusReasonExpired,
+}
+
+// fakeWatches hands out fake watchers in order, recording the options of every watch.
+type fakeWatches struct {
+	mu       sync.Mutex
+	watchers []*watch.FakeWatcher
+	opts     []metav1.ListOptions
+}
+
+func (f *fakeWatches) watch(_ context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.opts = append(f.opts, opts)
+	if len(f.opts) > len(f.watchers) {
+		return watch.NewFake(), nil
+	}
+	return f.watchers[len(f.opts)-1], nil
+}
+
+func (f *fakeWatches) resourceVersions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	versions := make([]string, 0, len(f.opts))
+	for _, opts := range f.opts {
+		versions = append(versions, opts.ResourceVersion)
+	}
+	return versions
+}
+
+// receiveEvents reads count events from events, failing the test if they do not arrive.
+func receiveEvents(t *testing.T, events <-chan Event[corev1.Pod], count int) []Event[corev1.Pod] {
+	t.Helper()
+	var received []Event[corev1.Pod]
+	for range count {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d events, want %d", len(received), count)
+		}
+	}
+	return received
+}
+
+func TestWatchChannelRelistsAfterExpiry(t *testing.T) {
+	first := watch.NewFakeWithChanSize(3, false)
+	first.Add(newWatchPod("a", "1"))
+	first.Add(newWatchPod("b", "2"))
+	first.Error(expiredStatus)
+	watches := &fakeWatches{watchers: []*watch.FakeWatcher{first}}
+
+	// While the watch was down, b changed, c was created, and a was deleted
+	listPods := func(context.Context, metav1.ListOptions) (runtime.Object, error) {
+		return &corev1.PodList{
+			ListMeta: metav1.ListMeta{ResourceVersion: "10"},
+			Items:    []corev1.Pod{*newWatchPod("b", "5"), *newWatchPod("c", "6")},
+		}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := WatchChannel[corev1.Pod](ctx, watches.watch, WatchChannelOptions{ListFunc: listPods})
+	if err != nil {
+		t.Fatalf("WatchChannel() error = %v", err)
+	}
+
+	want := []struct {
+		eventType watch.EventType
+		name      string
+	}{
+		{watch.Added, "a"},
+		{watch.Added, "b"},
+		{watch.Modified, "b"},
+		{watch.Added, "c"},
+		{watch.Deleted, "a"},
+	}
+	received := receiveEvents(t, events, len(want))
+	for i, event := range received {
+		if event.Type != want[i].eventType || event.Object.Name != want[i].name {
+			t.Errorf("event %d = %s %s, want %s %s", i, event.Type, event.Object.Name, want[i].eventType, want[i].name)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+	if got := watches.resourceVersions(); len(got) != 2 || got[1] != "10" {
+		t.Errorf("watches started at resource versions %q, want the second at the list's %q", got, "10")
+	}
+}
+
+func TestWatchChannelWithoutListFunc(t *testing.T) {
+	first := watch.NewFakeWithChanSize(2, false)
+	first.Add(newWatchPod("a", "1"))
+	first.Error(expiredStatus)
+	watches := &fakeWatches{watchers: []*watch.FakeWatcher{first}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := WatchChannel[corev1.Pod](ctx, watches.watch, WatchChannelOptions{})
+	if err != nil {
+		t.Fatalf("WatchChannel() error = %v", err)
+	}
+	receiveEvents(t, events, 1)
+
+	// The expired watch restarts from the current state
+	deadline := time.Now().Add(5 * time.Second)
+	for len(watches.resourceVersions()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	for range events {
+	}
+	if got := watches.resourceVersions(); len(got) != 2 || got[1] != "" {
+		t.Errorf("watches started at resource versions %q, want the second from the current state", got)
+	}
+}
+
+func TestWatchChannelBacksOff(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		starts []time.Time
+	)
+	// Every watch closes at once without delivering an event
+	closedWatch := func(context.Context, metav1.ListOptions) (watch.Interface, error) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		watcher := watch.NewFake()
+		watcher.Stop()
+		return watcher, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := WatchChannel[corev1.Pod](ctx, closedWatch, WatchChannelOptions{})
+	if err != nil {
+		t.Fatalf("WatchChannel() error = %v", err)
+	}
+
+	time.Sleep(2 * defaultConnectRetryBaseDelay)
+	cancel()
+	for range events {
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Started at 0, restarted after 500ms, then waiting 1s when cancelled at 1s
+	if len(starts) != 2 {
+		t.Fatalf("watch started %d times in %s, want 2", len(starts), 2*defaultConnectRetryBaseDelay)
+	}
+	if gap := starts[1].Sub(starts[0]); gap < defaultConnectRetryBaseDelay {
+		t.Errorf("watch restarted after %s, want at least %s", gap, defaultConnectRetryBaseDelay)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// restartedAtAnnotation is the pod template annotation kubectl sets to trigger a rollout restart.
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// Workload kinds accepted in a WorkloadRef.
+const (
+	WorkloadDeployment  = "Deployment"
+	WorkloadStatefulSet = "StatefulSet"
+	WorkloadDaemonSet   = "DaemonSet"
+)
+
+// WorkloadRef identifies a workload in the same namespace that can be rollout-restarted.
+type WorkloadRef struct {
+	// Kind is one of WorkloadDeployment, WorkloadStatefulSet, or WorkloadDaemonSet.
+	Kind string
+
+	// Name is the name of the workload.
+	Name string
+}
+
+func (w WorkloadRef) String() string {
+	return w.Kind + "/" + w.Name
+}
+
+// RolloutRestart triggers a rolling restart of a workload the same way
+// `kubectl rollout restart` does: by stamping the current time into a pod template
+// annotation with a merge patch, which causes the controller to replace every pod.
+//
+// Parameters:
+//
+//	ctx: The context used for the patch request.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	ns: The namespace of the workload.
+//	workload: The workload to restart.
+//
+// Returns:
+//
+//	An error if the kind is unsupported or the patch fails, otherwise nil.
+func RolloutRestart(ctx context.Context, clientset kubernetes.Interface, ns string, workload WorkloadRef) error {
+	patch := fmt.Appendf(nil,
+		`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`,
+		restartedAtAnnotation, time.Now().Format(time.RFC3339),
+	)
+
+	var err error
+	switch workload.Kind {
+	case WorkloadDeployment:
+		_, err = clientset.AppsV1().Deployments(ns).Patch(ctx, workload.Name, types.MergePatchType, patch,
+			metav1.PatchOptions{})
+	case WorkloadStatefulSet:
+		_, err = clientset.AppsV1().StatefulSets(ns).Patch(ctx, workload.Name, types.MergePatchType, patch,
+			metav1.PatchOptions{})
+	case WorkloadDaemonSet:
+		_, err = clientset.AppsV1().DaemonSets(ns).Patch(ctx, workload.Name, types.MergePatchType, patch,
+			metav1.PatchOptions{})
+	default:
+		return fmt.Errorf("unsupported workload kind %q for %s", workload.Kind, workload.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restart %s in namespace %s: %w", workload, ns, err)
+	}
+
+	return nil
+}
+
+// UpdateSecretAndRestart replaces the data of a Secret and then rollout-restarts the
+// workloads that consume it, so the new values are picked up by fresh pods.
+//
+// The Secret is updated first; conflicts with concurrent writers are retried. If the
+// update fails, no workload is touched. Once the Secret is updated, a restart is attempted
+// for every workload even if some fail, and the failures are returned together, so a
+// single broken reference does not leave the remaining workloads running stale config.
+//
+// Parameters:
+//
+//	ctx: The context used for the API requests.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	ns: The namespace of the Secret and the workloads.
+//	secretName: The name of the Secret to update. It must already exist.
+//	data: The new contents of the Secret, replacing the existing data entirely.
+//	workloads: The workloads to restart after the update.
+//
+// Returns:
+//
+//	An error if the Secret update fails, or a joined error naming every workload that
+//	could not be restarted, otherwise nil.
+func UpdateSecretAndRestart(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	ns, secretName string,
+	data map[string][]byte,
+	workloads []WorkloadRef,
+) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := clientset.CoreV1().Secrets(ns).Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		secret.Data = data
+		secret.StringData = nil
+		_, err = clientset.CoreV1().Secrets(ns).Update(ctx, secret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update secret %s in namespace %s: %w", secretName, ns, err)
+	}
+
+	var errs []error
+	for _, workload := range workloads {
+		if err := RolloutRestart(ctx, clientset, ns, workload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("secret %s updated but %d of %d workloads failed to restart: %w",
+			secretName, len(errs), len(workloads), errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// StatefulSetRolloutStatus reports whether a StatefulSet has finished rolling out, with
+// the same progress message `kubectl rollout status` prints.
+//
+// StatefulSets update pods one ordinal at a time and, unlike Deployments, report progress
+// through revisions: the rollout is complete once every replica is ready and the current
+// revision has caught up with the update revision. With a partitioned rolling update,
+// only the pods at or above the partition ordinal are updated, so the rollout is complete
+// once those have been. Rollout status is only defined for the RollingUpdate strategy.
+//
+// Parameters:
+//
+//	ctx: The context used for the get request.
+//	clientset: The Kubernetes client used to talk to the cluster.
+//	ns: The namespace of the StatefulSet.
+//	name: The name of the StatefulSet.
+//
+// Returns:
+//
+//	Whether the rollout is complete.
+//	A human readable progress message.
+//	An error if the StatefulSet cannot be read or does not use the RollingUpdate strategy.
+func StatefulSetRolloutStatus(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	ns, name string,
+) (bool, string, error) {
+	sts, err := clientset.AppsV1().StatefulSets(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, "", fmt.Errorf("failed to get statefulset %s/%s: %w", ns, name, err)
+	}
+
+	strategy := sts.Spec.UpdateStrategy
+	if strategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		return false, "", fmt.Errorf("rollout status is only available for %s strategy type",
+			appsv1.RollingUpdateStatefulSetStrategyType)
+	}
+	if sts.Status.ObservedGeneration == 0 || sts.Generation > sts.Status.ObservedGeneration {
+		return false, "Waiting for statefulset spec update to be observed...", nil
+	}
+
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	if sts.Status.ReadyReplicas < replicas {
+		return false, fmt.Sprintf("Waiting for %d pods to be ready...", replicas-sts.Status.ReadyReplicas), nil
+	}
+
+	if strategy.RollingUpdate != nil && strategy.RollingUpdate.Partition != nil {
+		toUpdate := replicas - *strategy.RollingUpdate.Partition
+		if sts.Status.UpdatedReplicas < toUpdate {
+			return false, fmt.Sprintf(
+				"Waiting for partitioned roll out to finish: %d out of %d new pods have been updated...",
+				sts.Status.UpdatedReplicas, toUpdate), nil
+		}
+		return true, fmt.Sprintf("partitioned roll out complete: %d new pods have been updated...",
+			sts.Status.UpdatedReplicas), nil
+	}
+
+	if sts.Status.UpdateRevision != sts.Status.CurrentRevision {
+		return false, fmt.Sprintf("waiting for statefulset rolling update to complete %d pods at revision %s...",
+			sts.Status.UpdatedReplicas, sts.Status.UpdateRevision), nil
+	}
+
+	return true, fmt.Sprintf("statefulset rolling update complete %d pods at revision %s...",
+		sts.Status.CurrentReplicas, sts.Status.CurrentRevision), nil
+}